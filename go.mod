@@ -1,3 +1,3 @@
-module vending-machine-go
+module github.com/doppelganger113/vending-machine-go
 
 go 1.15
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Since the order of products does not matter, a pop pattern is fully described by
+// how many products are popped from the front of each bucket. The search walks the
+// possible slices one by one and tries every valid prefix length, longest first,
+// so that plans touching fewer buckets are found earlier.
+//
+// Bucket [1 2 3 5 5] offers prefixes [], [1], [1 2], [1 2 3] for products 1 2 3 4 5,
+// and every choice is followed by the same decision for the next bucket with the
+// remaining products. A branch is cut as soon as the remaining buckets don't hold
+// enough of some remaining product, which keeps the search complete but bounded.
+type noOrderSearch struct {
+	buckets []*PossibleBucketSlice
+	// Products still needed, by product, and their total count
+	remaining map[int]int
+	left      int
+	// Distinct products sorted, so that the remaining products have a stable key
+	productIds []int
+	// Number popped from each bucket in the current branch
+	popped []int
+	// Count of each product from the bucket at the index to the last one
+	suffixCounts []map[int]int
+
+	// Set when the search has to check for cancellation, see step
+	ctx           context.Context
+	err           error
+	steps         int
+	size          int
+	partial       *[]*PopPattern
+	partialPopped int
+}
+
+func newNoOrderSearch(possibleSlice []*PossibleBucketSlice, products []int) *noOrderSearch {
+	remaining := make(map[int]int)
+	for _, product := range products {
+		remaining[product]++
+	}
+	productIds := make([]int, 0, len(remaining))
+	for product := range remaining {
+		productIds = append(productIds, product)
+	}
+	sort.Ints(productIds)
+
+	suffixCounts := make([]map[int]int, len(possibleSlice)+1)
+	suffixCounts[len(possibleSlice)] = map[int]int{}
+	for i := len(possibleSlice) - 1; i >= 0; i-- {
+		counts := make(map[int]int, len(suffixCounts[i+1]))
+		for product, count := range suffixCounts[i+1] {
+			counts[product] = count
+		}
+		for _, product := range possibleSlice[i].Values {
+			counts[product]++
+		}
+		suffixCounts[i] = counts
+	}
+
+	return &noOrderSearch{
+		buckets:      possibleSlice,
+		remaining:    remaining,
+		left:         len(products),
+		size:         len(products),
+		productIds:   productIds,
+		popped:       make([]int, len(possibleSlice)),
+		suffixCounts: suffixCounts,
+	}
+}
+
+// Checks whether the buckets from index i onward can still provide what is left.
+func (s *noOrderSearch) feasible(i int) bool {
+	for product, count := range s.remaining {
+		if count > s.suffixCounts[i][product] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Identifies the state of the search from bucket index i onward, the buckets before
+// it can't change what is still possible.
+func (s *noOrderSearch) key(i int) string {
+	var builder strings.Builder
+	builder.WriteString(strconv.Itoa(i))
+	for _, product := range s.productIds {
+		builder.WriteByte(',')
+		builder.WriteString(strconv.Itoa(s.remaining[product]))
+	}
+
+	return builder.String()
+}
+
+// Builds the pop patterns of the current branch, indexes refer to the possible slices.
+func (s *noOrderSearch) patterns() *[]*PopPattern {
+	patterns := []*PopPattern{}
+	for i, numberPopped := range s.popped {
+		if numberPopped == 0 {
+			continue
+		}
+		patterns = append(patterns, &PopPattern{
+			Index:        i,
+			NumberPopped: numberPopped,
+		})
+	}
+
+	return &patterns
+}
+
+// Calls yield for every distinct pop pattern, which can be read with patterns, until
+// yield returns false. Returns false if the search was stopped by yield or by the
+// context, in which case the search can't be reused.
+func (s *noOrderSearch) run(i int, yield func() bool) bool {
+	if s.ctx != nil && !s.step() {
+		return false
+	}
+	if s.left == 0 {
+		return yield()
+	}
+	if i == len(s.buckets) || !s.feasible(i) {
+		return true
+	}
+
+	values := s.buckets[i].Values
+
+	// Take as many as possible from the front, the prefix stays valid when shortened
+	taken := 0
+	for taken < len(values) && s.remaining[values[taken]] > 0 {
+		s.remaining[values[taken]]--
+		s.left--
+		taken++
+	}
+
+	for numberPopped := taken; numberPopped >= 0; numberPopped-- {
+		s.popped[i] = numberPopped
+		if !s.run(i+1, yield) {
+			return false
+		}
+		// put the last popped product back before trying a shorter prefix
+		if numberPopped > 0 {
+			s.remaining[values[numberPopped-1]]++
+			s.left++
+		}
+	}
+	s.popped[i] = 0
+
+	return true
+}
+
+// FindBacktrackingPattern searches every combination of bucket prefixes, so unlike
+// FindFirstNoOrderPattern it only returns nil when there is no pattern at all.
+// Order of products does not matter.
+func FindBacktrackingPattern(possibleSlice *[]*PossibleBucketSlice, products *[]int) *[]*PopPattern {
+	var found *[]*PopPattern
+
+	search := newNoOrderSearch(*possibleSlice, *products)
+	search.run(0, func() bool {
+		found = search.patterns()
+		return false
+	})
+
+	return found
+}
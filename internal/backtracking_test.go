@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestFindBacktrackingPattern(t *testing.T) {
+	data := []struct {
+		scenario          string
+		possibleSlice     *[]*PossibleBucketSlice
+		possibleSliceCopy *[]*PossibleBucketSlice
+		products          *[]int
+		expectedPatterns  *[]*PopPattern
+	}{
+		{
+			scenario: "First row instant",
+			possibleSlice: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{1, 3, 5, 2, 4},
+				},
+				{
+					Index:  1,
+					Values: []int{2, 5, 4, 3, 1},
+				},
+			},
+			possibleSliceCopy: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{1, 3, 5, 2, 4},
+				},
+				{
+					Index:  1,
+					Values: []int{2, 5, 4, 3, 1},
+				},
+			},
+			products: &[]int{1, 2, 3, 4, 5},
+			expectedPatterns: &[]*PopPattern{
+				{
+					Index:        0,
+					NumberPopped: 5,
+				},
+			},
+		},
+		{
+			scenario: "Same product in two buckets",
+			possibleSlice: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{1},
+				},
+				{
+					Index:  1,
+					Values: []int{1},
+				},
+			},
+			possibleSliceCopy: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{1},
+				},
+				{
+					Index:  1,
+					Values: []int{1},
+				},
+			},
+			products: &[]int{1, 1},
+			expectedPatterns: &[]*PopPattern{
+				{
+					Index:        0,
+					NumberPopped: 1,
+				},
+				{
+					Index:        1,
+					NumberPopped: 1,
+				},
+			},
+		},
+		{
+			scenario: "Needs to backtrack over first bucket",
+			possibleSlice: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{3},
+				},
+				{
+					Index:  1,
+					Values: []int{1, 3},
+				},
+				{
+					Index:  2,
+					Values: []int{2},
+				},
+			},
+			possibleSliceCopy: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{3},
+				},
+				{
+					Index:  1,
+					Values: []int{1, 3},
+				},
+				{
+					Index:  2,
+					Values: []int{2},
+				},
+			},
+			products: &[]int{3, 1, 2},
+			expectedPatterns: &[]*PopPattern{
+				{
+					Index:        0,
+					NumberPopped: 1,
+				},
+				{
+					Index:        1,
+					NumberPopped: 1,
+				},
+				{
+					Index:        2,
+					NumberPopped: 1,
+				},
+			},
+		},
+		{
+			scenario: "Impossible scenario",
+			possibleSlice: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{2, 1, 2, 2, 5},
+				},
+				{
+					Index:  1,
+					Values: []int{3, 5, 2, 3, 1},
+				},
+				{
+					Index:  1,
+					Values: []int{1, 2, 1, 4, 1},
+				},
+			},
+			possibleSliceCopy: &[]*PossibleBucketSlice{
+				{
+					Index:  0,
+					Values: []int{2, 1, 2, 2, 5},
+				},
+				{
+					Index:  1,
+					Values: []int{3, 5, 2, 3, 1},
+				},
+				{
+					Index:  1,
+					Values: []int{1, 2, 1, 4, 1},
+				},
+			},
+			products:         &[]int{1, 2, 3, 4, 5},
+			expectedPatterns: nil,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			patterns := FindBacktrackingPattern(d.possibleSlice, d.products)
+			if patterns == nil && d.expectedPatterns != nil {
+				t.Fatal("Got nil patterns\n")
+			}
+
+			if err := assertEqualPatterns(patterns, d.expectedPatterns); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, originalSlice := range *d.possibleSliceCopy {
+				val := *d.possibleSlice
+				if areEqualInt(originalSlice.Values, val[i].Values) == false {
+					t.Fatalf("Arrays have mutated\n")
+				}
+			}
+		})
+	}
+}
+
+func TestFindAndPopByOrder_Backtracking(t *testing.T) {
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		products               []int
+		expectedVendingMachine [][]int
+	}{
+		{
+			scenario: "Readme example",
+			vendingMachine: [][]int{
+				{1, 2, 3, 5, 5},
+				{2, 5, 4, 3, 1},
+				{3, 5, 4, 1, 1},
+				{5, 1, 1, 1, 1},
+			},
+			products: []int{1, 2, 3, 4, 5},
+			expectedVendingMachine: [][]int{
+				{2, 3, 5, 5},
+				{1},
+				{3, 5, 4, 1, 1},
+				{5, 1, 1, 1, 1},
+			},
+		},
+		{
+			scenario: "Skipped bucket keeps its index",
+			vendingMachine: [][]int{
+				{9, 1},
+				{1, 9},
+			},
+			products: []int{1},
+			expectedVendingMachine: [][]int{
+				{9, 1},
+				{9},
+			},
+		},
+		{
+			scenario: "Greedy scan misses it",
+			vendingMachine: [][]int{
+				{1, 2},
+				{1, 2},
+			},
+			products: []int{1, 1},
+			expectedVendingMachine: [][]int{
+				{2},
+				{2},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			if err := FindAndPopByOrder(&d.vendingMachine, &d.products, FindBacktrackingPattern); err != nil {
+				t.Fatal(err)
+			}
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, d.vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, d.vendingMachine[i], bucket)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+type BatchResult struct {
+	// Pop patterns by order, nil for the dropped orders
+	Patterns []*[]*PopPattern
+	// Indexes of the orders that were not served
+	Dropped []int
+}
+
+// Serving orders one at a time can pop a bucket that a later order depends on, so the
+// batch is searched as a whole. The state of the search is the number popped from each
+// bucket by the orders served so far, and for each state we remember how many of the
+// remaining orders can still be served.
+type batchSearch struct {
+	vendingMachine *[][]int
+	orders         []*[]int
+	requireAll     bool
+	offsets        []int
+	memo           map[string]int
+}
+
+// Vending machine as it is after the orders served so far.
+func (s *batchSearch) view() *[][]int {
+	view := make([][]int, len(*s.vendingMachine))
+	for i, bucket := range *s.vendingMachine {
+		view[i] = bucket[s.offsets[i]:]
+	}
+
+	return &view
+}
+
+func (s *batchSearch) key(k int) string {
+	var builder strings.Builder
+	builder.WriteString(strconv.Itoa(k))
+	for _, offset := range s.offsets {
+		builder.WriteByte(',')
+		builder.WriteString(strconv.Itoa(offset))
+	}
+
+	return builder.String()
+}
+
+func (s *batchSearch) apply(patterns *[]*PopPattern, sign int) {
+	for _, pattern := range *patterns {
+		s.offsets[pattern.Index] += sign * pattern.NumberPopped
+	}
+}
+
+// Maximum number of orders from index k onward that can be served, or -1 when every
+// order is required and that's not possible.
+func (s *batchSearch) best(k int) int {
+	if k == len(s.orders) {
+		return 0
+	}
+
+	key := s.key(k)
+	if best, ok := s.memo[key]; ok {
+		return best
+	}
+
+	best := -1
+	EnumeratePopPatterns(s.view(), s.orders[k], 0, func(patterns *[]*PopPattern) bool {
+		s.apply(patterns, 1)
+		next := s.best(k + 1)
+		s.apply(patterns, -1)
+
+		if next >= 0 && next+1 > best {
+			best = next + 1
+		}
+		// can't do better than serving all of them
+		return best < len(s.orders)-k
+	})
+
+	if !s.requireAll && best < len(s.orders)-k-1 {
+		if next := s.best(k + 1); next > best {
+			best = next
+		}
+	}
+
+	s.memo[key] = best
+
+	return best
+}
+
+// Walks the remembered best choices, serving an order whenever that keeps the best count.
+func (s *batchSearch) result() *BatchResult {
+	result := &BatchResult{
+		Patterns: make([]*[]*PopPattern, len(s.orders)),
+	}
+
+	for k := range s.orders {
+		best := s.best(k)
+
+		var chosen *[]*PopPattern
+		EnumeratePopPatterns(s.view(), s.orders[k], 0, func(patterns *[]*PopPattern) bool {
+			s.apply(patterns, 1)
+			next := s.best(k + 1)
+			if next >= 0 && next+1 == best {
+				chosen = patterns
+				return false
+			}
+			s.apply(patterns, -1)
+			return true
+		})
+
+		if chosen == nil {
+			result.Dropped = append(result.Dropped, k)
+			continue
+		}
+		result.Patterns[k] = chosen
+	}
+
+	return result
+}
+
+// FindBatchPopPatterns finds the pop patterns that serve as many of the orders as
+// possible, one after another in the given order. When the same number of orders can
+// be served in different ways, earlier orders are preferred. If requireAll is set it
+// returns ImpossibleErr unless all of them can be served.
+// Order of products within an order does not matter.
+func FindBatchPopPatterns(vendingMachine *[][]int, orders []*[]int, requireAll bool) (*BatchResult, error) {
+	search := &batchSearch{
+		vendingMachine: vendingMachine,
+		orders:         orders,
+		requireAll:     requireAll,
+		offsets:        make([]int, len(*vendingMachine)),
+		memo:           map[string]int{},
+	}
+
+	if search.best(0) < 0 {
+		return nil, ImpossibleErr
+	}
+
+	return search.result(), nil
+}
+
+// FindAndPopBatch pops the patterns of every served order, in order.
+func FindAndPopBatch(vendingMachine *[][]int, orders []*[]int, requireAll bool) (*BatchResult, error) {
+	result, err := FindBatchPopPatterns(vendingMachine, orders, requireAll)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patterns := range result.Patterns {
+		if patterns != nil {
+			PopByPattern(vendingMachine, patterns)
+		}
+	}
+
+	return result, nil
+}
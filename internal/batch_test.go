@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestFindAndPopBatch(t *testing.T) {
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		orders                 [][]int
+		requireAll             bool
+		expectedPatterns       []*[]*PopPattern
+		expectedDropped        []int
+		expectedVendingMachine [][]int
+		expectedErr            error
+	}{
+		{
+			scenario: "First order must leave a product for the second",
+			vendingMachine: [][]int{
+				{3, 3},
+				{3, 1},
+			},
+			orders: [][]int{{3}, {1}},
+			expectedPatterns: []*[]*PopPattern{
+				{{Index: 1, NumberPopped: 1}},
+				{{Index: 1, NumberPopped: 1}},
+			},
+			expectedDropped: []int{},
+			expectedVendingMachine: [][]int{
+				{3, 3},
+				{},
+			},
+		},
+		{
+			scenario: "Drops the impossible order",
+			vendingMachine: [][]int{
+				{1},
+				{2},
+			},
+			orders: [][]int{{1, 1}, {2}},
+			expectedPatterns: []*[]*PopPattern{
+				nil,
+				{{Index: 1, NumberPopped: 1}},
+			},
+			expectedDropped: []int{0},
+			expectedVendingMachine: [][]int{
+				{1},
+				{},
+			},
+		},
+		{
+			scenario: "Prefers earlier orders",
+			vendingMachine: [][]int{
+				{1},
+			},
+			orders: [][]int{{1}, {1}},
+			expectedPatterns: []*[]*PopPattern{
+				{{Index: 0, NumberPopped: 1}},
+				nil,
+			},
+			expectedDropped: []int{1},
+			expectedVendingMachine: [][]int{
+				{},
+			},
+		},
+		{
+			scenario: "Requires all orders",
+			vendingMachine: [][]int{
+				{1},
+				{2},
+			},
+			orders:      [][]int{{1, 1}, {2}},
+			requireAll:  true,
+			expectedErr: ImpossibleErr,
+			expectedVendingMachine: [][]int{
+				{1},
+				{2},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			orders := []*[]int{}
+			for i := range d.orders {
+				orders = append(orders, &d.orders[i])
+			}
+
+			result, err := FindAndPopBatch(&d.vendingMachine, orders, d.requireAll)
+			if err != d.expectedErr {
+				t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+			}
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, d.vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, d.vendingMachine[i], bucket)
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			if areEqualInt(result.Dropped, d.expectedDropped) == false {
+				t.Fatalf("Expected dropped %+v, got %+v", d.expectedDropped, result.Dropped)
+			}
+			for i, patterns := range d.expectedPatterns {
+				if err := assertEqualPatterns(result.Patterns[i], patterns); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Product struct {
+	SKU  string
+	Name string
+	// Price in cents
+	Price    int
+	Category string
+}
+
+// Catalog interns product SKUs to the ids used by the vending machine, numbered from 1
+// in the order they were added.
+type Catalog struct {
+	ids      map[string]int
+	products map[int]*Product
+	nextId   int
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		ids:      map[string]int{},
+		products: map[int]*Product{},
+		nextId:   1,
+	}
+}
+
+// Add returns the id of the new product, SKUs have to be unique and not empty.
+func (c *Catalog) Add(product Product) (int, error) {
+	id := c.nextId
+	if err := c.AddWithId(id, product); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// AddWithId adds the product under the id of choice, which must not be taken.
+func (c *Catalog) AddWithId(id int, product Product) error {
+	if len(product.SKU) == 0 {
+		return InvalidArgument
+	}
+	if _, ok := c.ids[product.SKU]; ok {
+		return InvalidArgument
+	}
+	if _, ok := c.products[id]; ok {
+		return InvalidArgument
+	}
+
+	c.ids[product.SKU] = id
+	c.products[id] = &product
+	if id >= c.nextId {
+		c.nextId = id + 1
+	}
+
+	return nil
+}
+
+// Intern returns the id of the SKU, adding a product without details when it is new.
+func (c *Catalog) Intern(sku string) (int, error) {
+	if id, ok := c.ids[sku]; ok {
+		return id, nil
+	}
+
+	return c.Add(Product{SKU: sku})
+}
+
+func (c *Catalog) Id(sku string) (int, bool) {
+	id, ok := c.ids[sku]
+	return id, ok
+}
+
+func (c *Catalog) Product(id int) (*Product, bool) {
+	product, ok := c.products[id]
+	return product, ok
+}
+
+// Name of the product to display, its SKU when it has no name or the id when it is
+// not in the catalog.
+func (c *Catalog) Name(id int) string {
+	product, ok := c.products[id]
+	if !ok {
+		return strconv.Itoa(id)
+	}
+	if len(product.Name) == 0 {
+		return product.SKU
+	}
+
+	return product.Name
+}
+
+// ParseCatalog reads products encoded like the buckets, each product being
+// <sku>,<name>,<price in cents>,<category> as in COLA,Coca Cola,150,drinks;WATER,Water,100,drinks
+func ParseCatalog(str string) (*Catalog, error) {
+	catalog := NewCatalog()
+	if len(str) == 0 {
+		return catalog, nil
+	}
+
+	for _, encoded := range strings.Split(str, ";") {
+		fields := strings.Split(encoded, ",")
+		if len(fields) != 4 {
+			return nil, InvalidArgument
+		}
+		price, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, InvalidArgument
+		}
+		_, err = catalog.Add(Product{
+			SKU:      fields[0],
+			Name:     fields[1],
+			Price:    price,
+			Category: fields[3],
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return catalog, nil
+}
+
+// CreateFromSKUString is CreateFromString with SKUs instead of product ids, every SKU
+// has to be in the catalog.
+func CreateFromSKUString(str string, catalog *Catalog) (*[][]int, error) {
+	matrix := [][]int{}
+
+	if len(str) == 0 {
+		return &matrix, nil
+	}
+
+	offset := 0
+	for i, bucket := range strings.Split(str, ";") {
+		parsedBucketProducts, err := parseSKUs(str, bucket, i, offset, catalog)
+		if err != nil {
+			return nil, err
+		}
+		matrix = append(matrix, parsedBucketProducts)
+		offset += len(bucket) + 1
+	}
+
+	return &matrix, nil
+}
+
+// ParseSKUInput is ParseInput with SKUs instead of product ids, every SKU has to be in
+// the catalog.
+func ParseSKUInput(input string, catalog *Catalog) (*[]int, error) {
+	products, err := parseSKUs(input, input, -1, 0, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	return &products, nil
+}
+
+// Parses the SKUs of a bucket or of an order like parseProducts, a SKU that is not in
+// the catalog is a ParseError.
+func parseSKUs(input string, str string, bucket int, offset int, catalog *Catalog) ([]int, error) {
+	products := []int{}
+	if len(str) == 0 || str == emptyEncoding {
+		return products, nil
+	}
+
+	for _, sku := range strings.Split(str, ",") {
+		id, ok := catalog.Id(sku)
+		if !ok {
+			return nil, &ParseError{
+				Input:  input,
+				Bucket: bucket,
+				Item:   len(products),
+				Offset: offset,
+				Token:  sku,
+			}
+		}
+		products = append(products, id)
+		offset += len(sku) + 1
+	}
+
+	return products, nil
+}
+
+// PrintPrettyWithCatalog is PrintPrettyWithStatus with the names of the products
+func PrintPrettyWithCatalog(vendingMachine *[][]int, catalog *Catalog, statuses *[]BucketStatus) {
+	fmt.Println("Vending machine")
+	for i, bucket := range *vendingMachine {
+		names := make([]string, 0, len(bucket))
+		for _, product := range bucket {
+			names = append(names, catalog.Name(product))
+		}
+		if status := statusOf(statuses, i); status != Active {
+			fmt.Printf("\t[%s] %s\n", strings.Join(names, ", "), status)
+			continue
+		}
+		fmt.Printf("\t[%s]\n", strings.Join(names, ", "))
+	}
+}
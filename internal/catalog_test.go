@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCatalog(t *testing.T) {
+	data := []struct {
+		scenario    string
+		str         string
+		expectedErr error
+	}{
+		{scenario: "Valid", str: "COLA,Coca Cola,150,drinks;WATER,Still water,100,drinks"},
+		{scenario: "Empty", str: ""},
+		{scenario: "Missing field", str: "COLA,Coca Cola,150", expectedErr: InvalidArgument},
+		{scenario: "Invalid price", str: "COLA,Coca Cola,1.5,drinks", expectedErr: InvalidArgument},
+		{scenario: "Duplicate SKU", str: "COLA,Coca Cola,150,drinks;COLA,Cola,100,drinks", expectedErr: InvalidArgument},
+		{scenario: "Empty SKU", str: ",Coca Cola,150,drinks", expectedErr: InvalidArgument},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			if _, err := ParseCatalog(d.str); err != d.expectedErr {
+				t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	catalog, err := ParseCatalog("COLA,Coca Cola,150,drinks;WATER,Still water,100,drinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, ok := catalog.Id("WATER")
+	if !ok || id != 2 {
+		t.Fatalf("Expected WATER to be 2, got %d", id)
+	}
+	product, ok := catalog.Product(id)
+	if !ok || product.Name != "Still water" || product.Price != 100 || product.Category != "drinks" {
+		t.Fatalf("Invalid product %+v", product)
+	}
+
+	if err := catalog.AddWithId(1, Product{SKU: "JUICE"}); err != InvalidArgument {
+		t.Fatalf("Expected taken id to be invalid, got %v", err)
+	}
+	if err := catalog.AddWithId(10, Product{SKU: "JUICE"}); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := catalog.Intern("CHIPS"); id != 11 {
+		t.Fatalf("Expected new SKU to follow the highest id, got %d", id)
+	}
+	if catalog.Name(11) != "CHIPS" || catalog.Name(1) != "Coca Cola" || catalog.Name(42) != "42" {
+		t.Fatalf("Invalid names %s %s %s", catalog.Name(11), catalog.Name(1), catalog.Name(42))
+	}
+}
+
+func TestFindAndPopByOrder_SKU(t *testing.T) {
+	catalog, err := ParseCatalog("COLA,Coca Cola,150,drinks;WATER,Still water,100,drinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendingMachine, err := CreateFromSKUString("COLA,WATER;WATER,COLA", catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := ParseSKUInput("WATER,COLA", catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if areEqualInt((*vendingMachine)[1], []int{2, 1}) == false || areEqualInt(*products, []int{2, 1}) == false {
+		t.Fatalf("Invalid ids %+v %+v", *vendingMachine, *products)
+	}
+
+	if err := FindAndPopByOrder(vendingMachine, products, FindBacktrackingPattern); err != nil {
+		t.Fatal(err)
+	}
+	if len((*vendingMachine)[0]) != 0 {
+		t.Fatalf("Invalid vending machine %+v", *vendingMachine)
+	}
+}
+
+func TestParseSKU_Unknown(t *testing.T) {
+	catalog, err := ParseCatalog("COLA,Coca Cola,150,drinks;WATER,Still water,100,drinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		scenario      string
+		parse         func(str string) error
+		str           string
+		expectedError ParseError
+	}{
+		{
+			scenario: "Bucket",
+			parse: func(str string) error {
+				_, err := CreateFromSKUString(str, catalog)
+				return err
+			},
+			str:           "COLA,WATER;WATER,WATR",
+			expectedError: ParseError{Input: "COLA,WATER;WATER,WATR", Bucket: 1, Item: 1, Offset: 17, Token: "WATR"},
+		},
+		{
+			scenario: "Order",
+			parse: func(str string) error {
+				_, err := ParseSKUInput(str, catalog)
+				return err
+			},
+			str:           "CLOA",
+			expectedError: ParseError{Input: "CLOA", Bucket: -1, Item: 0, Offset: 0, Token: "CLOA"},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			err := d.parse(d.str)
+			var parseErr *ParseError
+			if !errors.Is(err, InvalidArgument) || !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a parse error, got %v", err)
+			}
+			if *parseErr != d.expectedError {
+				t.Fatalf("Expected %+v, got %+v", d.expectedError, *parseErr)
+			}
+		})
+	}
+
+	if _, ok := catalog.Id("WATR"); ok {
+		t.Fatalf("Expected the unknown SKU to stay out of the catalog")
+	}
+}
@@ -0,0 +1,342 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConfigError tells which line of the configuration is invalid, it matches
+// InvalidArgument
+type ConfigError struct {
+	Line    int
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s", InvalidArgument, e.Line, e.Message)
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == InvalidArgument
+}
+
+// Config is a vending machine described in a file, with the names of its products.
+type Config struct {
+	Machine *MachineDocument
+	// Product names by id
+	Names map[int]string
+}
+
+// Catalog of the named products, known by their id as SKU.
+func (c *Config) Catalog() *Catalog {
+	catalog := NewCatalog()
+	for id, name := range c.Names {
+		// Ids are unique, so is their SKU
+		_ = catalog.AddWithId(id, Product{SKU: strconv.Itoa(id), Name: name})
+	}
+
+	return catalog
+}
+
+// Values of the configuration are strings, integers or arrays of integers on a line
+type configValue struct {
+	line     int
+	str      *string
+	number   *int
+	products []int
+}
+
+type configParser struct {
+	config *Config
+	// Current table, "" before the first one
+	table string
+	// Keys of the current table, with the line they were set on
+	keys map[string]int
+	// Lines where each bucket starts
+	bucketLines []int
+	// Line of the products table, 0 until it is listed
+	productsLine int
+}
+
+// LoadConfig reads the configuration from a file, see ParseConfig.
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseConfig(file)
+}
+
+// ParseConfig reads a configuration written in a subset of TOML, with comments,
+// strings, integers and arrays of integers on a single line:
+//
+//	name = "lobby"
+//
+//	[products]
+//	1 = "Coca Cola"
+//
+//	[[buckets]]
+//	products = [1, 2, 1]
+//	capacity = 10
+//	status = "jammed"
+//
+// Keys outside of a table are kept as metadata. Buckets are in the order they are
+// listed, each with its products from the front.
+func ParseConfig(reader io.Reader) (*Config, error) {
+	parser := &configParser{
+		config: &Config{
+			Machine: &MachineDocument{Buckets: [][]int{}},
+			Names:   map[int]string{},
+		},
+		keys: map[string]int{},
+	}
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := parser.parseLine(line, strings.TrimSpace(scanner.Text())); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := parser.validate(); err != nil {
+		return nil, err
+	}
+
+	return parser.config, nil
+}
+
+func (p *configParser) parseLine(line int, text string) error {
+	if len(text) == 0 || text[0] == '#' {
+		return nil
+	}
+
+	if strings.HasPrefix(text, "[") {
+		return p.parseTable(line, text)
+	}
+
+	separator := strings.Index(text, "=")
+	if separator == -1 {
+		return &ConfigError{Line: line, Message: fmt.Sprintf("expected key = value, got %q", text)}
+	}
+	key := strings.TrimSpace(text[:separator])
+	if !isBareKey(key) {
+		return &ConfigError{Line: line, Message: fmt.Sprintf("invalid key %q", key)}
+	}
+	if previous, ok := p.keys[key]; ok {
+		return &ConfigError{Line: line, Message: fmt.Sprintf("%s is already set on line %d", key, previous)}
+	}
+	p.keys[key] = line
+
+	value, err := parseConfigValue(line, strings.TrimSpace(text[separator+1:]))
+	if err != nil {
+		return err
+	}
+
+	return p.set(key, value)
+}
+
+func (p *configParser) parseTable(line int, text string) error {
+	text = stripComment(text)
+	switch text {
+	case "[products]":
+		if p.productsLine > 0 {
+			return &ConfigError{Line: line, Message: fmt.Sprintf("products are already listed on line %d", p.productsLine)}
+		}
+		p.table = "products"
+		p.productsLine = line
+	case "[[buckets]]":
+		p.table = "buckets"
+		p.config.Machine.Buckets = append(p.config.Machine.Buckets, nil)
+		p.bucketLines = append(p.bucketLines, line)
+	default:
+		return &ConfigError{Line: line, Message: fmt.Sprintf("unknown table %s", text)}
+	}
+	p.keys = map[string]int{}
+
+	return nil
+}
+
+func (p *configParser) set(key string, value *configValue) error {
+	switch p.table {
+	case "":
+		if value.str == nil {
+			return &ConfigError{Line: value.line, Message: fmt.Sprintf("%s must be a string", key)}
+		}
+		if p.config.Machine.Metadata == nil {
+			p.config.Machine.Metadata = map[string]string{}
+		}
+		p.config.Machine.Metadata[key] = *value.str
+	case "products":
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return &ConfigError{Line: value.line, Message: fmt.Sprintf("product id %q is not an integer", key)}
+		}
+		if value.str == nil {
+			return &ConfigError{Line: value.line, Message: fmt.Sprintf("name of product %d must be a string", id)}
+		}
+		p.config.Names[id] = *value.str
+	case "buckets":
+		return p.setBucket(key, value)
+	}
+
+	return nil
+}
+
+func (p *configParser) setBucket(key string, value *configValue) error {
+	machine := p.config.Machine
+	bucket := len(machine.Buckets) - 1
+
+	switch key {
+	case "products":
+		if value.products == nil {
+			return &ConfigError{Line: value.line, Message: "products must be an array of integers"}
+		}
+		machine.Buckets[bucket] = value.products
+	case "capacity":
+		if value.number == nil || *value.number < 0 {
+			return &ConfigError{Line: value.line, Message: "capacity must be an integer of at least 0"}
+		}
+		for len(machine.Capacities) <= bucket {
+			machine.Capacities = append(machine.Capacities, 0)
+		}
+		machine.Capacities[bucket] = *value.number
+	case "status":
+		if value.str == nil {
+			return &ConfigError{Line: value.line, Message: "status must be a string"}
+		}
+		status, err := ParseBucketStatus(*value.str)
+		if err != nil {
+			return &ConfigError{Line: value.line, Message: fmt.Sprintf("unknown status %q, expected active, jammed or maintenance", *value.str)}
+		}
+		for len(machine.Statuses) <= bucket {
+			machine.Statuses = append(machine.Statuses, Active)
+		}
+		machine.Statuses[bucket] = status
+	default:
+		return &ConfigError{Line: value.line, Message: fmt.Sprintf("unknown bucket key %s, expected products, capacity or status", key)}
+	}
+
+	return nil
+}
+
+// Checks what can only be checked once the whole bucket is known
+func (p *configParser) validate() error {
+	machine := p.config.Machine
+	for i, bucket := range machine.Buckets {
+		if bucket == nil {
+			return &ConfigError{Line: p.bucketLines[i], Message: fmt.Sprintf("bucket %d has no products", i)}
+		}
+		capacity := capacityOf(&machine.Capacities, i)
+		if capacity > 0 && len(bucket) > capacity {
+			return &ConfigError{Line: p.bucketLines[i], Message: fmt.Sprintf("bucket %d has %d products, more than its capacity of %d", i, len(bucket), capacity)}
+		}
+	}
+
+	return nil
+}
+
+func parseConfigValue(line int, text string) (*configValue, error) {
+	value := &configValue{line: line}
+
+	if strings.HasPrefix(text, `"`) {
+		str, rest, err := parseConfigString(text)
+		if err != nil {
+			return nil, &ConfigError{Line: line, Message: err.Error()}
+		}
+		if rest = strings.TrimSpace(rest); len(rest) > 0 && rest[0] != '#' {
+			return nil, &ConfigError{Line: line, Message: fmt.Sprintf("unexpected %q after the string", rest)}
+		}
+		value.str = &str
+		return value, nil
+	}
+
+	text = stripComment(text)
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") {
+			return nil, &ConfigError{Line: line, Message: "arrays must end with ] on the same line"}
+		}
+		value.products = []int{}
+		for _, item := range strings.Split(text[1:len(text)-1], ",") {
+			item = strings.TrimSpace(item)
+			if len(item) == 0 {
+				continue
+			}
+			product, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, &ConfigError{Line: line, Message: fmt.Sprintf("product %q is not an integer", item)}
+			}
+			value.products = append(value.products, product)
+		}
+		return value, nil
+	}
+
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return nil, &ConfigError{Line: line, Message: fmt.Sprintf("invalid value %q", text)}
+	}
+	value.number = &number
+
+	return value, nil
+}
+
+// Parses the string at the start of the text, returning what is after it
+func parseConfigString(text string) (string, string, error) {
+	var builder strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			return builder.String(), text[i+1:], nil
+		case '\\':
+			if i+1 == len(text) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			i++
+			switch text[i] {
+			case '"', '\\':
+				builder.WriteByte(text[i])
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				return "", "", fmt.Errorf("unknown escape \\%c", text[i])
+			}
+		default:
+			builder.WriteByte(text[i])
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+func stripComment(text string) string {
+	if comment := strings.Index(text, "#"); comment != -1 {
+		text = text[:comment]
+	}
+
+	return strings.TrimSpace(text)
+}
+
+func isBareKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lobbyConfig = `# Vending machine of the lobby
+name = "lobby"
+site = "Main \"A\" building"
+
+[products]
+1 = "Coca Cola"
+2 = "Still water" # bottled
+
+[[buckets]]
+products = [1, 2, 3, 5, 5]
+capacity = 10
+
+[[buckets]]
+products = [2, 5, 4, 3, 1]
+status = "jammed"
+
+[[buckets]]
+products = [3, 5, 4, 1, 1,]
+
+[[buckets]]
+products = [5, 1, 1, 1, 1]
+status = "maintenance"
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(lobbyConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := CreateFromString("1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.Machine.VendingMachine(), expected) {
+		t.Errorf("expected %+v got %+v", *expected, *config.Machine.VendingMachine())
+	}
+	if !reflect.DeepEqual(config.Machine.Capacities, []int{10}) {
+		t.Errorf("unexpected capacities %+v", config.Machine.Capacities)
+	}
+	if !reflect.DeepEqual(config.Machine.Statuses, []BucketStatus{Active, Jammed, Active, Maintenance}) {
+		t.Errorf("unexpected statuses %+v", config.Machine.Statuses)
+	}
+	if !reflect.DeepEqual(config.Machine.Metadata, map[string]string{"name": "lobby", "site": `Main "A" building`}) {
+		t.Errorf("unexpected metadata %+v", config.Machine.Metadata)
+	}
+	if !reflect.DeepEqual(config.Names, map[int]string{1: "Coca Cola", 2: "Still water"}) {
+		t.Errorf("unexpected names %+v", config.Names)
+	}
+
+	catalog := config.Catalog()
+	if catalog.Name(2) != "Still water" || catalog.Name(3) != "3" {
+		t.Errorf("unexpected catalog names %s %s", catalog.Name(2), catalog.Name(3))
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	data := []struct {
+		scenario     string
+		config       string
+		expectedLine int
+	}{
+		{scenario: "Unknown table", config: "name = \"a\"\n[bucket]\n", expectedLine: 2},
+		{scenario: "Missing value", config: "\n\nname\n", expectedLine: 3},
+		{scenario: "Metadata is not a string", config: "floor = 2\n", expectedLine: 1},
+		{scenario: "Invalid product", config: "[[buckets]]\nproducts = [1, a]\n", expectedLine: 2},
+		{scenario: "Array on several lines", config: "[[buckets]]\nproducts = [1,\n2]\n", expectedLine: 2},
+		{scenario: "Unknown status", config: "[[buckets]]\nproducts = [1]\n\nstatus = \"broken\"\n", expectedLine: 4},
+		{scenario: "Negative capacity", config: "[[buckets]]\ncapacity = -1\n", expectedLine: 2},
+		{scenario: "Unknown bucket key", config: "[[buckets]]\nproduct = [1]\n", expectedLine: 2},
+		{scenario: "Key set twice", config: "[[buckets]]\nproducts = [1]\nproducts = [2]\n", expectedLine: 3},
+		{scenario: "Bucket without products", config: "[[buckets]]\nproducts = [1]\n[[buckets]]\ncapacity = 1\n", expectedLine: 3},
+		{scenario: "Over capacity", config: "[[buckets]]\ncapacity = 1\nproducts = [1, 2]\n", expectedLine: 1},
+		{scenario: "Product id", config: "[products]\ncola = \"Coca Cola\"\n", expectedLine: 2},
+		{scenario: "Products listed twice", config: "[products]\n1 = \"a\"\n[products]\n", expectedLine: 3},
+		{scenario: "Unterminated string", config: "name = \"lobby\n", expectedLine: 1},
+		{scenario: "After the string", config: "name = \"lobby\" x\n", expectedLine: 1},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			_, err := ParseConfig(strings.NewReader(d.config))
+			if !errors.Is(err, InvalidArgument) {
+				t.Fatalf("expected %v got %v", InvalidArgument, err)
+			}
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("expected a config error got %v", err)
+			}
+			if configErr.Line != d.expectedLine {
+				t.Errorf("expected line %d got %d: %v", d.expectedLine, configErr.Line, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lobby.toml")
+	if err := ioutil.WriteFile(path, []byte(lobbyConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Machine.Buckets) != 4 {
+		t.Errorf("expected 4 buckets got %d", len(config.Machine.Buckets))
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.toml")); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to exist got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// How many search steps are taken between checks of the context
+const contextCheckInterval = 1024
+
+var TimeoutErr = errors.New("timeout")
+
+// TimeoutError is returned when the context is done before a pattern is found. It
+// matches TimeoutErr with errors.Is, as well as the error of the context.
+type TimeoutError struct {
+	// Pattern that popped the most products so far, nil when there is none
+	Partial *[]*PopPattern
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s: %s", TimeoutErr, e.Err)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == TimeoutErr
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// ContextPatternFunc is a PatternFunc that stops once the context is done, returning
+// a *TimeoutError.
+type ContextPatternFunc func(ctx context.Context, possibleSlice *[]*PossibleBucketSlice, products *[]int) (*[]*PopPattern, error)
+
+// WithContext checks the context before calling the pattern function, which is fine
+// for the pattern functions that don't search for long.
+func WithContext(fn PatternFunc) ContextPatternFunc {
+	return func(ctx context.Context, possibleSlice *[]*PossibleBucketSlice, products *[]int) (*[]*PopPattern, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, &TimeoutError{Err: err}
+		}
+		return fn(possibleSlice, products), nil
+	}
+}
+
+// Counts a search step and tells whether the search may go on, remembering the
+// pattern that got the furthest in case it doesn't.
+func (s *noOrderSearch) step() bool {
+	s.steps++
+	if s.steps%contextCheckInterval == 0 {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return false
+		}
+	}
+
+	if popped := s.size - s.left; s.partial == nil || popped > s.partialPopped {
+		s.partial = s.patterns()
+		s.partialPopped = popped
+	}
+
+	return true
+}
+
+// FindBacktrackingPatternContext is FindBacktrackingPattern that checks the context
+// as it searches.
+func FindBacktrackingPatternContext(ctx context.Context, possibleSlice *[]*PossibleBucketSlice, products *[]int) (*[]*PopPattern, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &TimeoutError{Err: err}
+	}
+
+	var found *[]*PopPattern
+
+	search := newNoOrderSearch(*possibleSlice, *products)
+	search.ctx = ctx
+	search.run(0, func() bool {
+		found = search.patterns()
+		return false
+	})
+
+	if search.err != nil {
+		partial := search.partial
+		if search.partialPopped == 0 {
+			partial = nil
+		}
+		return nil, &TimeoutError{Partial: partial, Err: search.err}
+	}
+
+	return found, nil
+}
+
+// FindCumulativePopPatternContext is FindCumulativePopPattern that stops once the
+// context is done, returning a *TimeoutError with the partial pattern of the search,
+// its indexes referring to the vending machine buckets.
+func FindCumulativePopPatternContext(ctx context.Context, vendingMachine *[][]int, products *[]int, fn ContextPatternFunc) (*[]*PopPattern, error) {
+	if len(*products) == 0 {
+		return &[]*PopPattern{}, nil
+	}
+
+	var possibleSlices []*PossibleBucketSlice
+
+	for i, bucket := range *vendingMachine {
+		if err := ctx.Err(); err != nil {
+			return nil, &TimeoutError{Err: err}
+		}
+
+		slice := ScanBucketSlice(bucket, products)
+		if len(*slice) == 0 {
+			continue
+		}
+
+		possibleSlices = append(possibleSlices, &PossibleBucketSlice{
+			Index:  i,
+			Values: *slice,
+		})
+
+		patterns, err := fn(ctx, &possibleSlices, products)
+		if err != nil {
+			var timeoutErr *TimeoutError
+			if errors.As(err, &timeoutErr) && timeoutErr.Partial != nil {
+				timeoutErr.Partial = toMachinePatterns(possibleSlices, timeoutErr.Partial)
+			}
+			return nil, err
+		}
+		if patterns != nil {
+			return toMachinePatterns(possibleSlices, patterns), nil
+		}
+	}
+
+	return nil, ImpossibleErr
+}
+
+// FindAndPopByOrderContext leaves the vending machine as it is when the context is
+// done before a pattern is found.
+func FindAndPopByOrderContext(ctx context.Context, vendingMachine *[][]int, products *[]int, fn ContextPatternFunc) error {
+	patterns, err := FindCumulativePopPatternContext(ctx, vendingMachine, products, fn)
+	if err == ImpossibleErr {
+		return Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	PopByPattern(vendingMachine, patterns)
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Context that is done after its error was checked a number of times
+type checkedContext struct {
+	context.Context
+	checks int
+}
+
+func (c *checkedContext) Err() error {
+	if c.checks == 0 {
+		return context.DeadlineExceeded
+	}
+	c.checks--
+	return nil
+}
+
+func TestFindAndPopByOrderContext(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 2, 3, 5, 5},
+		{2, 5, 4, 3, 1},
+	}
+	products := []int{1, 2, 3, 4, 5}
+
+	err := FindAndPopByOrderContext(context.Background(), &vendingMachine, &products, FindBacktrackingPatternContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if areEqualInt(vendingMachine[0], []int{2, 3, 5, 5}) == false || areEqualInt(vendingMachine[1], []int{1}) == false {
+		t.Fatalf("Invalid vending machine %+v", vendingMachine)
+	}
+}
+
+func TestFindAndPopByOrderContext_Cancelled(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 2, 3, 5, 5},
+		{2, 5, 4, 3, 1},
+	}
+	products := []int{1, 2, 3, 4, 5}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := FindAndPopByOrderContext(ctx, &vendingMachine, &products, WithContext(FindFirstNoOrderPattern))
+	if !errors.Is(err, TimeoutErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if areEqualInt(vendingMachine[0], []int{1, 2, 3, 5, 5}) == false {
+		t.Fatalf("Vending machine has mutated %+v", vendingMachine)
+	}
+}
+
+func TestFindCumulativePopPatternContext_Partial(t *testing.T) {
+	// Impossible as each 2 comes with a 1, which takes long to rule out without memoizing
+	vendingMachine := [][]int{}
+	for i := 0; i < 50; i++ {
+		vendingMachine = append(vendingMachine, []int{1, 2, 3})
+	}
+	// Nothing to offer, so that the possible slices don't match the bucket indexes
+	vendingMachine[0] = []int{9}
+	products := []int{1, 1, 2, 2, 2}
+
+	// Lets every bucket through and times out while searching all of them
+	ctx := &checkedContext{Context: context.Background(), checks: 2 * len(vendingMachine)}
+
+	_, err := FindCumulativePopPatternContext(ctx, &vendingMachine, &products, FindBacktrackingPatternContext)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if timeoutErr.Partial == nil {
+		t.Fatal("Expected a partial pattern")
+	}
+
+	popped := 0
+	for _, pattern := range *timeoutErr.Partial {
+		if pattern.Index == 0 {
+			t.Fatal("Partial pattern does not refer to the vending machine")
+		}
+		popped += pattern.NumberPopped
+	}
+	if popped != 4 {
+		t.Fatalf("Expected 4 products in the partial pattern, got %d", popped)
+	}
+}
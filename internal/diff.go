@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var PatchConflictErr = errors.New("diff does not apply to the vending machine")
+
+// PatchError tells which bucket the diff does not apply to, it matches PatchConflictErr
+type PatchError struct {
+	Bucket int
+	Reason string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%s: bucket %d %s", PatchConflictErr, e.Bucket, e.Reason)
+}
+
+func (e *PatchError) Is(target error) bool {
+	return target == PatchConflictErr
+}
+
+// BucketDiff is how a bucket changed, products are vended from the front and restocked
+// at the back, so anything else is a replacement of the whole bucket.
+type BucketDiff struct {
+	Index int `json:"index"`
+	// Products taken from the front
+	Removed []int `json:"removed"`
+	// Products added to the back
+	Appended []int `json:"appended"`
+	// Nothing was kept, Removed is what the bucket had and Appended what it has
+	Replaced bool `json:"replaced"`
+}
+
+type MachineDiff struct {
+	// Only the buckets that changed, by index
+	Buckets []*BucketDiff `json:"buckets"`
+}
+
+// CopyVendingMachine copies the buckets, to keep a state to compare with.
+func CopyVendingMachine(vendingMachine *[][]int) *[][]int {
+	copied := make([][]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		copied[i] = append([]int{}, bucket...)
+	}
+
+	return &copied
+}
+
+// Diff compares two states of the same vending machine, which must have the same
+// number of buckets.
+func Diff(before *[][]int, after *[][]int) (*MachineDiff, error) {
+	if len(*before) != len(*after) {
+		return nil, InvalidArgument
+	}
+
+	diff := &MachineDiff{Buckets: []*BucketDiff{}}
+	for i := range *before {
+		if bucketDiff := diffBucket(i, (*before)[i], (*after)[i]); bucketDiff != nil {
+			diff.Buckets = append(diff.Buckets, bucketDiff)
+		}
+	}
+
+	return diff, nil
+}
+
+// The fewest products removed from the front, so that what is left of the bucket
+// before is the start of the bucket after.
+func diffBucket(index int, before []int, after []int) *BucketDiff {
+	removed := 0
+	for ; removed < len(before); removed++ {
+		kept := before[removed:]
+		if len(kept) <= len(after) && isPrefix(kept, after) {
+			break
+		}
+	}
+
+	appended := after[len(before)-removed:]
+	if removed == 0 && len(appended) == 0 {
+		return nil
+	}
+
+	return &BucketDiff{
+		Index:    index,
+		Removed:  append([]int{}, before[:removed]...),
+		Appended: append([]int{}, appended...),
+		Replaced: removed == len(before) && removed > 0 && len(appended) > 0,
+	}
+}
+
+func isPrefix(prefix []int, slice []int) bool {
+	for i, product := range prefix {
+		if slice[i] != product {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *MachineDiff) String() string {
+	if len(d.Buckets) == 0 {
+		return "No changes\n"
+	}
+
+	var builder strings.Builder
+	for _, bucket := range d.Buckets {
+		if bucket.Replaced {
+			builder.WriteString(fmt.Sprintf("Bucket %d: replaced %+v with %+v\n", bucket.Index, bucket.Removed, bucket.Appended))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("Bucket %d:", bucket.Index))
+		if len(bucket.Removed) > 0 {
+			builder.WriteString(fmt.Sprintf(" removed %+v from the front", bucket.Removed))
+		}
+		if len(bucket.Appended) > 0 {
+			builder.WriteString(fmt.Sprintf(" appended %+v to the back", bucket.Appended))
+		}
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+func (d *MachineDiff) JSON() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func DiffFromJSON(data []byte) (*MachineDiff, error) {
+	diff := &MachineDiff{}
+	if err := json.Unmarshal(data, diff); err != nil {
+		return nil, InvalidArgument
+	}
+
+	return diff, nil
+}
+
+// Patch applies the diff after checking every bucket starts with the removed
+// products, or holds exactly them when it is replaced. A bucket changes at most once
+// in a diff. Nothing is changed when one of the buckets does not match.
+func Patch(vendingMachine *[][]int, diff *MachineDiff) error {
+	patched := map[int]bool{}
+	for _, bucketDiff := range diff.Buckets {
+		if bucketDiff.Index < 0 || bucketDiff.Index >= len(*vendingMachine) {
+			return &PatchError{Bucket: bucketDiff.Index, Reason: "does not exist"}
+		}
+		if patched[bucketDiff.Index] {
+			return &PatchError{Bucket: bucketDiff.Index, Reason: "changes more than once"}
+		}
+		patched[bucketDiff.Index] = true
+		bucket := (*vendingMachine)[bucketDiff.Index]
+		if len(bucketDiff.Removed) > len(bucket) || !isPrefix(bucketDiff.Removed, bucket) {
+			return &PatchError{Bucket: bucketDiff.Index, Reason: fmt.Sprintf("does not start with %+v", bucketDiff.Removed)}
+		}
+		if bucketDiff.Replaced && len(bucketDiff.Removed) != len(bucket) {
+			return &PatchError{Bucket: bucketDiff.Index, Reason: fmt.Sprintf("is not %+v", bucketDiff.Removed)}
+		}
+	}
+
+	for _, bucketDiff := range diff.Buckets {
+		kept := (*vendingMachine)[bucketDiff.Index][len(bucketDiff.Removed):]
+		patched := make([]int, 0, len(kept)+len(bucketDiff.Appended))
+		patched = append(patched, kept...)
+		(*vendingMachine)[bucketDiff.Index] = append(patched, bucketDiff.Appended...)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := [][]int{
+		{1, 2, 3},
+		{4, 5},
+		{6},
+		{7, 8},
+		{9},
+	}
+	after := [][]int{
+		{3},
+		{4, 5, 5, 5},
+		{6},
+		{1},
+		{},
+	}
+	expected := []*BucketDiff{
+		{Index: 0, Removed: []int{1, 2}, Appended: []int{}},
+		{Index: 1, Removed: []int{}, Appended: []int{5, 5}},
+		{Index: 3, Removed: []int{7, 8}, Appended: []int{1}, Replaced: true},
+		{Index: 4, Removed: []int{9}, Appended: []int{}},
+	}
+
+	diff, err := Diff(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Buckets) != len(expected) {
+		t.Fatalf("Expected %d changed buckets, got %d", len(expected), len(diff.Buckets))
+	}
+	for i, bucketDiff := range expected {
+		got := diff.Buckets[i]
+		if got.Index != bucketDiff.Index || got.Replaced != bucketDiff.Replaced ||
+			areEqualInt(got.Removed, bucketDiff.Removed) == false ||
+			areEqualInt(got.Appended, bucketDiff.Appended) == false {
+			t.Fatalf("Expected %+v, got %+v", *bucketDiff, *got)
+		}
+	}
+
+	expectedString := "Bucket 0: removed [1 2] from the front\n" +
+		"Bucket 1: appended [5 5] to the back\n" +
+		"Bucket 3: replaced [7 8] with [1]\n" +
+		"Bucket 4: removed [9] from the front\n"
+	if diff.String() != expectedString {
+		t.Fatalf("Invalid rendering\n%s", diff.String())
+	}
+
+	if _, err := Diff(&before, &[][]int{{1}}); err != InvalidArgument {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	before := [][]int{{1, 2, 3}, {4, 5}, {7, 8}}
+	after := [][]int{{3}, {4, 5, 5}, {1}}
+
+	diff, err := Diff(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := diff.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DiffFromJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Patch(&before, decoded); err != nil {
+		t.Fatal(err)
+	}
+	for i, bucket := range after {
+		if areEqualInt(bucket, before[i]) == false {
+			t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, before[i], bucket)
+		}
+	}
+
+	// The diff was already applied
+	err = Patch(&before, decoded)
+	var patchErr *PatchError
+	if !errors.Is(err, PatchConflictErr) || !errors.As(err, &patchErr) || patchErr.Bucket != 0 {
+		t.Fatalf("Expected conflict on bucket 0, got %v", err)
+	}
+	if areEqualInt(before[1], []int{4, 5, 5}) == false {
+		t.Fatalf("Vending machine has changed on conflict %+v", before)
+	}
+}
+
+func TestPatch_SameBucketTwice(t *testing.T) {
+	vendingMachine := [][]int{{1, 2}, {3}}
+	diff := &MachineDiff{Buckets: []*BucketDiff{
+		{Index: 0, Removed: []int{1}, Appended: []int{}},
+		{Index: 0, Removed: []int{1}, Appended: []int{}},
+	}}
+
+	err := Patch(&vendingMachine, diff)
+	var patchErr *PatchError
+	if !errors.Is(err, PatchConflictErr) || !errors.As(err, &patchErr) || patchErr.Bucket != 0 {
+		t.Fatalf("Expected conflict on bucket 0, got %v", err)
+	}
+	if areEqualInt(vendingMachine[0], []int{1, 2}) == false {
+		t.Fatalf("Vending machine has changed on conflict %+v", vendingMachine)
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+// Highest count returned by CountPopPatterns when there is no limit
+const maxCount = int(^uint(0) >> 1)
+
+// EnumeratePopPatterns calls fn with every distinct pop pattern of the vending machine,
+// until fn returns false or limit patterns were yielded, limit 0 meaning no limit.
+// Pattern indexes refer to the vending machine buckets, each pattern pops a bucket at
+// most once as order of products does not matter. Returns the number of patterns yielded.
+func EnumeratePopPatterns(vendingMachine *[][]int, products *[]int, limit int, fn func(patterns *[]*PopPattern) bool) int {
+	possibleSlices := scanPossibleSlices(vendingMachine, products)
+	yielded := 0
+
+	search := newNoOrderSearch(possibleSlices, *products)
+	search.run(0, func() bool {
+		yielded++
+		if !fn(toMachinePatterns(possibleSlices, search.patterns())) {
+			return false
+		}
+		return limit <= 0 || yielded < limit
+	})
+
+	return yielded
+}
+
+// CountPopPatterns returns how many distinct pop patterns EnumeratePopPatterns would
+// yield, up to limit with 0 meaning no limit. No pattern is built and counts of equal
+// search states are shared, so it stays fast where enumerating would not.
+func CountPopPatterns(vendingMachine *[][]int, products *[]int, limit int) int {
+	if limit <= 0 {
+		limit = maxCount
+	}
+	search := newNoOrderSearch(scanPossibleSlices(vendingMachine, products), *products)
+
+	return search.count(0, limit, map[string]int{})
+}
+
+// Counts the pop patterns from bucket index i onward, saturating at limit. Counts above
+// the limit are not needed, as the limit is reached regardless of the other branches.
+func (s *noOrderSearch) count(i int, limit int, memo map[string]int) int {
+	if s.left == 0 {
+		return 1
+	}
+	if i == len(s.buckets) || !s.feasible(i) {
+		return 0
+	}
+
+	key := s.key(i)
+	if count, ok := memo[key]; ok {
+		return count
+	}
+
+	values := s.buckets[i].Values
+
+	taken := 0
+	for taken < len(values) && s.remaining[values[taken]] > 0 {
+		s.remaining[values[taken]]--
+		s.left--
+		taken++
+	}
+
+	count := 0
+	for numberPopped := taken; numberPopped >= 0; numberPopped-- {
+		if count < limit {
+			count += s.count(i+1, limit, memo)
+			// also guards against overflow, each count is at most limit
+			if count >= limit || count < 0 {
+				count = limit
+			}
+		}
+		if numberPopped > 0 {
+			s.remaining[values[numberPopped-1]]++
+			s.left++
+		}
+	}
+
+	memo[key] = count
+
+	return count
+}
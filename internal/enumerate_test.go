@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestEnumeratePopPatterns(t *testing.T) {
+	data := []struct {
+		scenario         string
+		vendingMachine   [][]int
+		products         []int
+		limit            int
+		expectedPatterns []*[]*PopPattern
+	}{
+		{
+			scenario: "Every pattern",
+			vendingMachine: [][]int{
+				{1, 2},
+				{9},
+				{2, 1},
+			},
+			products: []int{1, 2},
+			expectedPatterns: []*[]*PopPattern{
+				{
+					{Index: 0, NumberPopped: 2},
+				},
+				{
+					{Index: 0, NumberPopped: 1},
+					{Index: 2, NumberPopped: 1},
+				},
+				{
+					{Index: 2, NumberPopped: 2},
+				},
+			},
+		},
+		{
+			scenario: "Limited",
+			vendingMachine: [][]int{
+				{1, 2},
+				{9},
+				{2, 1},
+			},
+			products: []int{1, 2},
+			limit:    2,
+			expectedPatterns: []*[]*PopPattern{
+				{
+					{Index: 0, NumberPopped: 2},
+				},
+				{
+					{Index: 0, NumberPopped: 1},
+					{Index: 2, NumberPopped: 1},
+				},
+			},
+		},
+		{
+			scenario: "Impossible",
+			vendingMachine: [][]int{
+				{1, 2},
+				{9},
+			},
+			products:         []int{1, 9, 9},
+			expectedPatterns: []*[]*PopPattern{},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			var patterns []*[]*PopPattern
+			yielded := EnumeratePopPatterns(&d.vendingMachine, &d.products, d.limit, func(p *[]*PopPattern) bool {
+				patterns = append(patterns, p)
+				return true
+			})
+			if yielded != len(d.expectedPatterns) || len(patterns) != len(d.expectedPatterns) {
+				t.Fatalf("Expected %d patterns, got %d", len(d.expectedPatterns), yielded)
+			}
+			for i, expected := range d.expectedPatterns {
+				if err := assertEqualPatterns(patterns[i], expected); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCountPopPatterns(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 2, 3, 5, 5},
+		{2, 5, 4, 3, 1},
+		{3, 5, 4, 1, 1},
+		{5, 1, 1, 1, 1},
+	}
+	products := []int{1, 2, 3, 4, 5}
+
+	seen := map[string]bool{}
+	EnumeratePopPatterns(&vendingMachine, &products, 0, func(patterns *[]*PopPattern) bool {
+		popped := []int{}
+		for _, pattern := range *patterns {
+			popped = append(popped, vendingMachine[pattern.Index][:pattern.NumberPopped]...)
+		}
+		sort.Ints(popped)
+		if areEqualInt(popped, products) == false {
+			t.Fatalf("Pattern pops %+v", popped)
+		}
+		seen[patternsKey(patterns)] = true
+		return true
+	})
+
+	if count := CountPopPatterns(&vendingMachine, &products, 0); count != len(seen) {
+		t.Fatalf("Expected count %d, got %d", len(seen), count)
+	}
+	if count := CountPopPatterns(&vendingMachine, &products, 2); count != 2 {
+		t.Fatalf("Expected limited count 2, got %d", count)
+	}
+}
+
+func patternsKey(patterns *[]*PopPattern) string {
+	key := ""
+	for _, pattern := range *patterns {
+		key += pattern.toString()
+	}
+	return key
+}
@@ -0,0 +1,454 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+var ReplayMismatchErr = errors.New("event does not match the vending machine")
+
+// Event is a change of the vending machine that can be applied again on replay
+type Event interface {
+	Apply(vendingMachine *[][]int) error
+	String() string
+	document() *eventDocument
+}
+
+// OrderVended keeps the pop patterns that were used, so the replay does not depend on
+// the pattern function finding the same ones.
+type OrderVended struct {
+	Products []int
+	Patterns []*PopPattern
+}
+
+// Apply checks that the patterns still pop the products of the order.
+func (e *OrderVended) Apply(vendingMachine *[][]int) error {
+	steps, err := PatternsToSteps(vendingMachine, &e.Patterns)
+	if err != nil {
+		return err
+	}
+
+	popped := make([]int, 0, len(*steps))
+	for _, step := range *steps {
+		popped = append(popped, step.Product)
+	}
+	if !sameProducts(popped, e.Products) {
+		return ReplayMismatchErr
+	}
+
+	return PopBySteps(vendingMachine, steps)
+}
+
+func (e *OrderVended) String() string {
+	return fmt.Sprintf("[OrderVended]: Products %+v Patterns %s", e.Products, patternsString(e.Patterns))
+}
+
+func (e *OrderVended) document() *eventDocument {
+	pops := make([]popDocument, 0, len(e.Patterns))
+	for _, pattern := range e.Patterns {
+		pops = append(pops, popDocument{Bucket: pattern.Index, Count: pattern.NumberPopped})
+	}
+
+	return &eventDocument{Type: "vended", Products: e.Products, Pops: pops}
+}
+
+type Restocked struct {
+	Restocks []*Restock
+}
+
+// Apply does not check capacities, they were checked when the restock happened.
+func (e *Restocked) Apply(vendingMachine *[][]int) error {
+	return RestockBuckets(vendingMachine, nil, &e.Restocks)
+}
+
+func (e *Restocked) String() string {
+	str := "[Restocked]:"
+	for _, restock := range e.Restocks {
+		str += fmt.Sprintf(" Bucket %d Products %+v Replace %t;", restock.Bucket, restock.Products, restock.Replace)
+	}
+
+	return str
+}
+
+func (e *Restocked) document() *eventDocument {
+	restocks := make([]restockDocument, 0, len(e.Restocks))
+	for _, restock := range e.Restocks {
+		restocks = append(restocks, restockDocument{
+			Bucket:   restock.Bucket,
+			Products: restock.Products,
+			Replace:  restock.Replace,
+		})
+	}
+
+	return &eventDocument{Type: "restocked", Restocks: restocks}
+}
+
+// ManuallyRemoved are products taken out of the front of a bucket without vending them
+type ManuallyRemoved struct {
+	Bucket int
+	Count  int
+}
+
+func (e *ManuallyRemoved) Apply(vendingMachine *[][]int) error {
+	return RemoveFromBucket(vendingMachine, e.Bucket, e.Count)
+}
+
+func (e *ManuallyRemoved) String() string {
+	return fmt.Sprintf("[ManuallyRemoved]: Bucket %d Count %d", e.Bucket, e.Count)
+}
+
+func (e *ManuallyRemoved) document() *eventDocument {
+	return &eventDocument{Type: "removed", Bucket: e.Bucket, Count: e.Count}
+}
+
+// ProductsReturned are products put back in front of a bucket, as a transaction does
+// when it is undone
+type ProductsReturned struct {
+	Bucket   int
+	Products []int
+}
+
+func (e *ProductsReturned) Apply(vendingMachine *[][]int) error {
+	if e.Bucket < 0 || e.Bucket >= len(*vendingMachine) {
+		return InvalidArgument
+	}
+	bucket := (*vendingMachine)[e.Bucket]
+	restored := make([]int, 0, len(e.Products)+len(bucket))
+	restored = append(restored, e.Products...)
+	(*vendingMachine)[e.Bucket] = append(restored, bucket...)
+
+	return nil
+}
+
+func (e *ProductsReturned) String() string {
+	return fmt.Sprintf("[ProductsReturned]: Bucket %d Products %+v", e.Bucket, e.Products)
+}
+
+func (e *ProductsReturned) document() *eventDocument {
+	return &eventDocument{Type: "returned", Bucket: e.Bucket, Products: e.Products}
+}
+
+// RemoveFromBucket takes products out of the front of the bucket.
+func RemoveFromBucket(vendingMachine *[][]int, bucket int, count int) error {
+	if bucket < 0 || bucket >= len(*vendingMachine) || count < 0 || count > len((*vendingMachine)[bucket]) {
+		return InvalidArgument
+	}
+	(*vendingMachine)[bucket] = (*vendingMachine)[bucket][count:]
+
+	return nil
+}
+
+// EventLog is an append only log of the changes of a vending machine. Its methods
+// change the vending machine and record the change when it succeeds, the patterns of
+// any search are recorded by popping them with PopByPattern.
+//
+// Copies of the log, made by Copy or by copying the struct, share the events they
+// have in common and each grows on its own.
+type EventLog struct {
+	// Shared by the copies of the log, which only see the first count events
+	events *[]Event
+	count  int
+}
+
+func (l *EventLog) Append(event Event) {
+	if l.events == nil || len(*l.events) != l.count {
+		// A copy grew the shared events past this log, which grows on its own copy
+		events := make([]Event, l.count, l.count+1)
+		copy(events, l.list())
+		l.events = &events
+	}
+	*l.events = append(*l.events, event)
+	l.count++
+}
+
+// Events of the log, shared with its copies
+func (l *EventLog) list() []Event {
+	if l.events == nil {
+		return nil
+	}
+
+	return (*l.events)[:l.count]
+}
+
+// Events returns a copy of the log, oldest event first
+func (l *EventLog) Events() []Event {
+	events := make([]Event, l.count)
+	copy(events, l.list())
+
+	return events
+}
+
+// Copy returns a log with the same events, that can grow on its own
+func (l *EventLog) Copy() *EventLog {
+	copied := *l
+
+	return &copied
+}
+
+// PopByPattern pops the patterns like PopByPattern does and records the products they
+// popped, returning InvalidArgument when the patterns pop more than a bucket has.
+func (l *EventLog) PopByPattern(vendingMachine *[][]int, patterns *[]*PopPattern) error {
+	steps, err := PatternsToSteps(vendingMachine, patterns)
+	if err != nil {
+		return err
+	}
+
+	products := make([]int, 0, len(*steps))
+	for _, step := range *steps {
+		products = append(products, step.Product)
+	}
+	PopByPattern(vendingMachine, patterns)
+	l.Append(&OrderVended{Products: products, Patterns: copyPatterns(*patterns)})
+
+	return nil
+}
+
+// PopBySteps pops the steps like PopBySteps does and records them, a pop pattern for
+// every step. Unlike PopBySteps nothing is popped when a step does not match, so that
+// every pop is recorded.
+func (l *EventLog) PopBySteps(vendingMachine *[][]int, steps *[]*VendStep) error {
+	// Popping a step only slices the bucket, the steps are tried on a copy of the slices
+	popped := make([][]int, len(*vendingMachine))
+	copy(popped, *vendingMachine)
+	if err := PopBySteps(&popped, steps); err != nil {
+		return err
+	}
+	copy(*vendingMachine, popped)
+
+	products := make([]int, 0, len(*steps))
+	patterns := make([]*PopPattern, 0, len(*steps))
+	for _, step := range *steps {
+		products = append(products, step.Product)
+		patterns = append(patterns, &PopPattern{Index: step.Bucket, NumberPopped: 1})
+	}
+	l.Append(&OrderVended{Products: products, Patterns: patterns})
+
+	return nil
+}
+
+func (l *EventLog) FindAndPopByOrder(vendingMachine *[][]int, products *[]int, fn PatternFunc) error {
+	patterns, err := FindCumulativePopPattern(vendingMachine, products, fn)
+	if err == ImpossibleErr {
+		return Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	return l.PopByPattern(vendingMachine, patterns)
+}
+
+func (l *EventLog) RestockBuckets(vendingMachine *[][]int, capacities *[]int, restocks *[]*Restock) error {
+	if err := RestockBuckets(vendingMachine, capacities, restocks); err != nil {
+		return err
+	}
+
+	recorded := make([]*Restock, 0, len(*restocks))
+	for _, restock := range *restocks {
+		recorded = append(recorded, &Restock{
+			Bucket:   restock.Bucket,
+			Products: append([]int{}, restock.Products...),
+			Replace:  restock.Replace,
+		})
+	}
+	l.Append(&Restocked{Restocks: recorded})
+
+	return nil
+}
+
+func (l *EventLog) RemoveFromBucket(vendingMachine *[][]int, bucket int, count int) error {
+	if err := RemoveFromBucket(vendingMachine, bucket, count); err != nil {
+		return err
+	}
+	l.Append(&ManuallyRemoved{Bucket: bucket, Count: count})
+
+	return nil
+}
+
+// Begin begins the transaction like Begin does, recording every pattern it pops and
+// every pattern it puts back.
+func (l *EventLog) Begin(vendingMachine *[][]int, patterns *[]*PopPattern) (*Transaction, error) {
+	transaction, err := Begin(vendingMachine, patterns)
+	if err != nil {
+		return nil, err
+	}
+	transaction.log = l
+
+	return transaction, nil
+}
+
+// Apply applies the events of the log to the vending machine, oldest first, stopping
+// at the first event that does not apply.
+func (l *EventLog) Apply(vendingMachine *[][]int) error {
+	for i, event := range l.list() {
+		if err := event.Apply(vendingMachine); err != nil {
+			return fmt.Errorf("event %d %s: %w", i, event, err)
+		}
+	}
+
+	return nil
+}
+
+// Replay rebuilds the vending machine from its initial encoding and the log, stopping
+// at the first event that does not apply.
+func Replay(initial string, log *EventLog) (*[][]int, error) {
+	vendingMachine, err := CreateFromString(initial)
+	if err != nil {
+		return nil, err
+	}
+	if err := log.Apply(vendingMachine); err != nil {
+		return nil, err
+	}
+
+	return vendingMachine, nil
+}
+
+// Events are encoded one JSON document per line, so that a log can be appended to.
+type eventDocument struct {
+	Type     string            `json:"type"`
+	Products []int             `json:"products,omitempty"`
+	Pops     []popDocument     `json:"pops,omitempty"`
+	Restocks []restockDocument `json:"restocks,omitempty"`
+	Bucket   int               `json:"bucket,omitempty"`
+	Count    int               `json:"count,omitempty"`
+}
+
+type restockDocument struct {
+	Bucket   int   `json:"bucket"`
+	Products []int `json:"products"`
+	Replace  bool  `json:"replace,omitempty"`
+}
+
+func (d *eventDocument) event() (Event, error) {
+	if d.Bucket < 0 || d.Count < 0 {
+		return nil, fmt.Errorf("%w: negative bucket or count", InvalidArgument)
+	}
+
+	switch d.Type {
+	case "vended":
+		patterns := make([]*PopPattern, 0, len(d.Pops))
+		for _, pop := range d.Pops {
+			if pop.Bucket < 0 || pop.Count < 0 {
+				return nil, fmt.Errorf("%w: negative bucket or count", InvalidArgument)
+			}
+			patterns = append(patterns, &PopPattern{Index: pop.Bucket, NumberPopped: pop.Count})
+		}
+		return &OrderVended{Products: append([]int{}, d.Products...), Patterns: patterns}, nil
+	case "restocked":
+		restocks := make([]*Restock, 0, len(d.Restocks))
+		for _, restock := range d.Restocks {
+			if restock.Bucket < 0 {
+				return nil, fmt.Errorf("%w: negative bucket", InvalidArgument)
+			}
+			restocks = append(restocks, &Restock{
+				Bucket:   restock.Bucket,
+				Products: append([]int{}, restock.Products...),
+				Replace:  restock.Replace,
+			})
+		}
+		return &Restocked{Restocks: restocks}, nil
+	case "removed":
+		return &ManuallyRemoved{Bucket: d.Bucket, Count: d.Count}, nil
+	case "returned":
+		return &ProductsReturned{Bucket: d.Bucket, Products: append([]int{}, d.Products...)}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown event %q", InvalidArgument, d.Type)
+}
+
+// Encode writes the events of the log one JSON document per line, oldest first.
+func (l *EventLog) Encode(writer io.Writer) error {
+	for _, event := range l.list() {
+		encoded, err := json.Marshal(event.document())
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(encoded, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Most bytes a line of the log may have
+const maxEventLine = 1 << 24
+
+// DecodeEventLog reads the events Encode writes, skipping empty lines. An event that
+// can't be decoded returns InvalidArgument with its line.
+func DecodeEventLog(reader io.Reader) (*EventLog, error) {
+	log := &EventLog{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		document := &eventDocument{}
+		if err := decodeJSON(scanner.Bytes(), document); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		event, err := document.event()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		log.Append(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// LoadEvents decodes the log and replays it into the vending machine, returning the
+// log so that it can be appended to.
+func LoadEvents(vendingMachine *[][]int, reader io.Reader) (*EventLog, error) {
+	log, err := DecodeEventLog(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := log.Apply(vendingMachine); err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+func copyPatterns(patterns []*PopPattern) []*PopPattern {
+	copied := make([]*PopPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		copied = append(copied, &PopPattern{Index: pattern.Index, NumberPopped: pattern.NumberPopped})
+	}
+
+	return copied
+}
+
+func patternsString(patterns []*PopPattern) string {
+	str := "["
+	for i, pattern := range patterns {
+		if i > 0 {
+			str += " "
+		}
+		str += fmt.Sprintf("%d:%d", pattern.Index, pattern.NumberPopped)
+	}
+
+	return str + "]"
+}
+
+func sameProducts(products []int, other []int) bool {
+	sorted := append([]int{}, products...)
+	sortedOther := append([]int{}, other...)
+	sort.Ints(sorted)
+	sort.Ints(sortedOther)
+
+	return reflect.DeepEqual(sorted, sortedOther)
+}
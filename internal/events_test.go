@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	initial := "1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1"
+	vendingMachine, err := CreateFromString(initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &EventLog{}
+	products := []int{1, 2, 3, 4, 5}
+	if err := log.FindAndPopByOrder(vendingMachine, &products, FindFirstNoOrderPattern); err != nil {
+		t.Fatal(err)
+	}
+	restocks := []*Restock{{Bucket: 1, Products: []int{7, 7}}}
+	if err := log.RestockBuckets(vendingMachine, nil, &restocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RemoveFromBucket(vendingMachine, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RemoveFromBucket(vendingMachine, 3, 10); err != InvalidArgument {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+
+	// Changing what was passed in must not change the log
+	products[0] = 9
+	restocks[0].Products[0] = 9
+
+	if len(log.Events()) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(log.Events()))
+	}
+
+	replayed, err := Replay(initial, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, bucket := range *vendingMachine {
+		if areEqualInt(bucket, (*replayed)[i]) == false {
+			t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, (*replayed)[i], bucket)
+		}
+	}
+}
+
+func TestReplay_Mismatch(t *testing.T) {
+	log := &EventLog{}
+	log.Append(&OrderVended{
+		Products: []int{1, 2},
+		Patterns: []*PopPattern{{Index: 0, NumberPopped: 1}, {Index: 0, NumberPopped: 1}},
+	})
+
+	if _, err := Replay("1,2;3", log); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Replay("1,3;2", log); !errors.Is(err, ReplayMismatchErr) {
+		t.Fatalf("Expected mismatch, got %v", err)
+	}
+	if _, err := Replay("1;2", log); !errors.Is(err, InvalidArgument) {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+}
+
+func TestEventLog_EncodeDecode(t *testing.T) {
+	initial := "1,2,3;2,5;3,5,4"
+	vendingMachine, err := CreateFromString(initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &EventLog{}
+	memoized, err := FindMemoizedPopPattern(vendingMachine, &[]int{5, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := log.PopByPattern(vendingMachine, memoized); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := PatternsToSteps(vendingMachine, &[]*PopPattern{{Index: 0, NumberPopped: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := log.PopBySteps(vendingMachine, steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RestockBuckets(vendingMachine, nil, &[]*Restock{{Bucket: 1, Products: []int{7}, Replace: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.RemoveFromBucket(vendingMachine, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	transaction, err := log.Begin(vendingMachine, &[]*PopPattern{{Index: 0, NumberPopped: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := log.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buffer.String(), "\n"); lines != 6 {
+		t.Fatalf("Expected 6 lines, got %d:\n%s", lines, buffer.String())
+	}
+
+	replayed, err := CreateFromString(initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadEvents(replayed, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Events()) != 6 {
+		t.Fatalf("Expected 6 events, got %d", len(loaded.Events()))
+	}
+	for i, bucket := range *vendingMachine {
+		if areEqualInt(bucket, (*replayed)[i]) == false {
+			t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, (*replayed)[i], bucket)
+		}
+	}
+}
+
+func TestEventLog_PopByStepsMismatch(t *testing.T) {
+	vendingMachine, err := CreateFromString("1,2;3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := &EventLog{}
+	steps := []*VendStep{
+		{Bucket: 0, Product: 1, Depth: 2},
+		{Bucket: 1, Product: 4, Depth: 1},
+	}
+	if err := log.PopBySteps(vendingMachine, &steps); err != StepMismatchErr {
+		t.Fatalf("Expected step mismatch, got %v", err)
+	}
+	if len(log.Events()) != 0 {
+		t.Fatalf("Expected no events, got %d", len(log.Events()))
+	}
+	if areEqualInt((*vendingMachine)[0], []int{1, 2}) == false || areEqualInt((*vendingMachine)[1], []int{3}) == false {
+		t.Fatalf("Expected nothing to be popped, got %+v", *vendingMachine)
+	}
+}
+
+func TestEventLog_Copy(t *testing.T) {
+	log := &EventLog{}
+	log.Append(&ManuallyRemoved{Bucket: 0, Count: 1})
+	copied := *log
+	log.Append(&ManuallyRemoved{Bucket: 1, Count: 1})
+	copied.Append(&ManuallyRemoved{Bucket: 2, Count: 1})
+	log.Append(&ManuallyRemoved{Bucket: 3, Count: 1})
+
+	expected := map[*EventLog][]int{log: {0, 1, 3}, &copied: {0, 2}}
+	for events, buckets := range expected {
+		removed := []int{}
+		for _, event := range events.Events() {
+			removed = append(removed, event.(*ManuallyRemoved).Bucket)
+		}
+		if areEqualInt(removed, buckets) == false {
+			t.Fatalf("Expected buckets %+v, got %+v", buckets, removed)
+		}
+	}
+}
+
+func TestDecodeEventLog_Invalid(t *testing.T) {
+	data := []struct {
+		scenario string
+		log      string
+	}{
+		{
+			scenario: "Unknown event",
+			log:      `{"type":"vended","products":[1],"pops":[{"bucket":0,"count":1}]}` + "\n" + `{"type":"sold"}`,
+		},
+		{
+			scenario: "Unknown field",
+			log:      `{"type":"removed","bucket":1,"count":1,"reason":"broken"}`,
+		},
+		{
+			scenario: "Negative count",
+			log:      `{"type":"removed","bucket":1,"count":-1}`,
+		},
+		{
+			scenario: "Negative pop",
+			log:      `{"type":"vended","products":[1],"pops":[{"bucket":-1,"count":1}]}`,
+		},
+		{
+			scenario: "Not JSON",
+			log:      `vended 1`,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			if _, err := DecodeEventLog(strings.NewReader(d.log)); !errors.Is(err, InvalidArgument) {
+				t.Fatalf("Expected invalid argument, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Expiry dates are kept next to the vending machine, bucket by bucket and product by
+// product from the front. The zero time, or a missing date, is a product that does
+// not expire.
+
+// Minutes a product without an expiry date counts for, far beyond any other product
+const neverExpires = 1 << 30
+
+func expiryOf(expiries *[][]time.Time, bucket int, depth int) time.Time {
+	if expiries == nil || bucket >= len(*expiries) || depth >= len((*expiries)[bucket]) {
+		return time.Time{}
+	}
+
+	return (*expiries)[bucket][depth]
+}
+
+func isExpired(expiry time.Time, now time.Time) bool {
+	return !expiry.IsZero() && !now.Before(expiry)
+}
+
+// Number of products in front of the first expired one, for every bucket
+func freshLimits(vendingMachine *[][]int, expiries *[][]time.Time, now time.Time) []int {
+	limits := make([]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		for limits[i] < len(bucket) && !isExpired(expiryOf(expiries, i, limits[i]), now) {
+			limits[i]++
+		}
+	}
+
+	return limits
+}
+
+// Fresh is the vending machine as the pattern functions see it, with every bucket
+// cut before its first expired product so that it can't be vended or popped past.
+func Fresh(vendingMachine *[][]int, expiries *[][]time.Time, now time.Time) *[][]int {
+	return limitedView(vendingMachine, freshLimits(vendingMachine, expiries, now))
+}
+
+// PopsExpired tells whether the patterns pop a product that expired by now.
+func PopsExpired(expiries *[][]time.Time, patterns *[]*PopPattern, now time.Time) bool {
+	for _, pattern := range *patterns {
+		for depth := 0; depth < pattern.NumberPopped; depth++ {
+			if isExpired(expiryOf(expiries, pattern.Index, depth), now) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SoonestExpiryCost is the cost model of the products that expire soonest, it adds up
+// the minutes left before each product popped from the bucket expires.
+func SoonestExpiryCost(expiries *[][]time.Time, now time.Time) BucketCostFunc {
+	return func(vendingMachine *[][]int, bucket int, numberPopped int) int {
+		cost := 0
+		for depth := 0; depth < numberPopped; depth++ {
+			expiry := expiryOf(expiries, bucket, depth)
+			if expiry.IsZero() {
+				cost += neverExpires
+				continue
+			}
+			cost += int(expiry.Sub(now) / time.Minute)
+		}
+
+		return cost
+	}
+}
+
+// FindFreshPopPattern finds the pop pattern that vends no expired product and, of
+// those, the one that vends the products expiring soonest.
+// Order of products does not matter.
+func FindFreshPopPattern(vendingMachine *[][]int, expiries *[][]time.Time, products *[]int, now time.Time) (*[]*PopPattern, error) {
+	return FindOptimalPopPatternByBucket(Fresh(vendingMachine, expiries, now), products, SoonestExpiryCost(expiries, now))
+}
+
+// FindAndPopFresh pops the fresh pop pattern from the vending machine and from the
+// expiry dates alike.
+func FindAndPopFresh(vendingMachine *[][]int, expiries *[][]time.Time, products *[]int, now time.Time) error {
+	patterns, err := FindFreshPopPattern(vendingMachine, expiries, products, now)
+	if err == ImpossibleErr {
+		return Explain(Fresh(vendingMachine, expiries, now), products)
+	}
+	if err != nil {
+		return err
+	}
+
+	PopByPattern(vendingMachine, patterns)
+	PopExpiries(expiries, patterns)
+
+	return nil
+}
+
+// PopExpiries pops the expiry dates of the products popped by the patterns.
+func PopExpiries(expiries *[][]time.Time, patterns *[]*PopPattern) {
+	for _, pattern := range *patterns {
+		if pattern.Index >= len(*expiries) {
+			continue
+		}
+		dates := (*expiries)[pattern.Index]
+		numberPopped := pattern.NumberPopped
+		if numberPopped > len(dates) {
+			numberPopped = len(dates)
+		}
+		(*expiries)[pattern.Index] = dates[numberPopped:]
+	}
+}
+
+type ExpiredProduct struct {
+	Bucket int
+	// Products in front of it
+	Depth   int
+	Product int
+	Expiry  time.Time
+}
+
+type ExpiryReport struct {
+	Expired []*ExpiredProduct
+	// Pops that take every expired product out, with the products in front of them
+	Clearing []*PopPattern
+}
+
+func (r *ExpiryReport) String() string {
+	if len(r.Expired) == 0 {
+		return "No expired products\n"
+	}
+
+	var builder strings.Builder
+	for _, expired := range r.Expired {
+		builder.WriteString(fmt.Sprintf("Product %d expired %s bucket %d depth %d\n",
+			expired.Product, expired.Expiry.Format(time.RFC3339), expired.Bucket, expired.Depth))
+	}
+	builder.WriteString(fmt.Sprintf("Cleared by pops %s\n", patternsString(r.Clearing)))
+
+	return builder.String()
+}
+
+// FindExpired lists the expired products and the pops that clear them, every bucket
+// is popped down to its last expired product.
+func FindExpired(vendingMachine *[][]int, expiries *[][]time.Time, now time.Time) *ExpiryReport {
+	report := &ExpiryReport{Expired: []*ExpiredProduct{}, Clearing: []*PopPattern{}}
+	for i, bucket := range *vendingMachine {
+		last := -1
+		for j, product := range bucket {
+			expiry := expiryOf(expiries, i, j)
+			if !isExpired(expiry, now) {
+				continue
+			}
+			report.Expired = append(report.Expired, &ExpiredProduct{
+				Bucket:  i,
+				Depth:   j,
+				Product: product,
+				Expiry:  expiry,
+			})
+			last = j
+		}
+		if last >= 0 {
+			report.Clearing = append(report.Clearing, &PopPattern{Index: i, NumberPopped: last + 1})
+		}
+	}
+
+	return report
+}
+
+// ClearExpired pops what FindExpired reports from the vending machine and the expiry
+// dates, returning the report.
+func ClearExpired(vendingMachine *[][]int, expiries *[][]time.Time, now time.Time) *ExpiryReport {
+	report := FindExpired(vendingMachine, expiries, now)
+	PopByPattern(vendingMachine, &report.Clearing)
+	PopExpiries(expiries, &report.Clearing)
+
+	return report
+}
+
+// ParseExpiries reads the expiry dates encoded like the buckets, each date as
+// 2006-01-02 or in RFC 3339 and an empty date for a product that does not expire,
+// as in 2021-05-01,;,2021-06-01
+func ParseExpiries(str string) (*[][]time.Time, error) {
+	expiries := [][]time.Time{}
+	if len(str) == 0 {
+		return &expiries, nil
+	}
+
+	for _, bucket := range strings.Split(str, ";") {
+		dates := []time.Time{}
+		for _, date := range strings.Split(bucket, ",") {
+			expiry, err := parseExpiry(date)
+			if err != nil {
+				return nil, err
+			}
+			dates = append(dates, expiry)
+		}
+		expiries = append(expiries, dates)
+	}
+
+	return &expiries, nil
+}
+
+func parseExpiry(date string) (time.Time, error) {
+	if len(date) == 0 {
+		return time.Time{}, nil
+	}
+	if expiry, err := time.Parse("2006-01-02", date); err == nil {
+		return expiry, nil
+	}
+	expiry, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, InvalidArgument
+	}
+
+	return expiry, nil
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindAndPopFresh(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	soon := now.AddDate(0, 0, 1)
+	later := now.AddDate(0, 0, 7)
+	nextWeek := now.AddDate(0, 0, 8)
+	last := now.AddDate(0, 11, 0)
+
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		expiries               [][]time.Time
+		products               []int
+		expectedVendingMachine [][]int
+		expectedExpiries       [][]time.Time
+		expectedErr            error
+	}{
+		{
+			scenario:               "Products without expiry dates",
+			vendingMachine:         [][]int{{1, 2}, {1}},
+			expiries:               [][]time.Time{},
+			products:               []int{1},
+			expectedVendingMachine: [][]int{{2}, {1}},
+			expectedExpiries:       [][]time.Time{},
+		},
+		{
+			scenario:               "Prefers the product expiring soonest",
+			vendingMachine:         [][]int{{1, 2}, {1}},
+			expiries:               [][]time.Time{{later}, {soon}},
+			products:               []int{1},
+			expectedVendingMachine: [][]int{{1, 2}, {}},
+			expectedExpiries:       [][]time.Time{{later}, {}},
+		},
+		{
+			scenario:               "Products that expire before the ones that don't",
+			vendingMachine:         [][]int{{1}, {1}},
+			expiries:               [][]time.Time{{}, {later}},
+			products:               []int{1},
+			expectedVendingMachine: [][]int{{1}, {}},
+			expectedExpiries:       [][]time.Time{{}, {}},
+		},
+		{
+			scenario:               "Leaves the product expiring last",
+			vendingMachine:         [][]int{{1, 2}, {2}},
+			expiries:               [][]time.Time{{soon, last}, {nextWeek}},
+			products:               []int{1, 2},
+			expectedVendingMachine: [][]int{{2}, {}},
+			expectedExpiries:       [][]time.Time{{last}, {}},
+		},
+		{
+			scenario:               "Refuses the expired product",
+			vendingMachine:         [][]int{{1}, {1, 2}},
+			expiries:               [][]time.Time{{past}, {soon, later}},
+			products:               []int{1},
+			expectedVendingMachine: [][]int{{1}, {2}},
+			expectedExpiries:       [][]time.Time{{past}, {later}},
+		},
+		{
+			scenario:               "Does not pop past an expired product",
+			vendingMachine:         [][]int{{1, 2}},
+			expiries:               [][]time.Time{{soon, past}},
+			products:               []int{1, 2},
+			expectedVendingMachine: [][]int{{1, 2}},
+			expectedExpiries:       [][]time.Time{{soon, past}},
+			expectedErr:            ImpossibleErr,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			err := FindAndPopFresh(&d.vendingMachine, &d.expiries, &d.products, now)
+			if d.expectedErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+				t.Fatalf("expected error %v got %v", d.expectedErr, err)
+			}
+			if !reflect.DeepEqual(d.vendingMachine, d.expectedVendingMachine) {
+				t.Errorf("expected %+v got %+v", d.expectedVendingMachine, d.vendingMachine)
+			}
+			if !reflect.DeepEqual(d.expiries, d.expectedExpiries) {
+				t.Errorf("expected expiries %+v got %+v", d.expectedExpiries, d.expiries)
+			}
+		})
+	}
+}
+
+func TestClearExpired(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	later := now.AddDate(0, 0, 7)
+
+	vendingMachine := [][]int{{1, 2, 3, 4}, {5}, {6}}
+	expiries := [][]time.Time{{past, later, past, later}, {later}, {now}}
+
+	report := ClearExpired(&vendingMachine, &expiries, now)
+
+	expectedExpired := []*ExpiredProduct{
+		{Bucket: 0, Depth: 0, Product: 1, Expiry: past},
+		{Bucket: 0, Depth: 2, Product: 3, Expiry: past},
+		{Bucket: 2, Depth: 0, Product: 6, Expiry: now},
+	}
+	if !reflect.DeepEqual(report.Expired, expectedExpired) {
+		t.Errorf("expected %+v got %+v", expectedExpired, report.Expired)
+	}
+	if err := assertEqualPatterns(&report.Clearing, &[]*PopPattern{
+		{Index: 0, NumberPopped: 3},
+		{Index: 2, NumberPopped: 1},
+	}); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(vendingMachine, [][]int{{4}, {5}, {}}) {
+		t.Errorf("unexpected vending machine %+v", vendingMachine)
+	}
+	if !reflect.DeepEqual(expiries, [][]time.Time{{later}, {later}, {}}) {
+		t.Errorf("unexpected expiries %+v", expiries)
+	}
+	if report := FindExpired(&vendingMachine, &expiries, now); len(report.Expired) != 0 || len(report.Clearing) != 0 {
+		t.Errorf("expected nothing left to clear got %s", report)
+	}
+}
+
+func TestParseExpiries(t *testing.T) {
+	expiries, err := ParseExpiries("2021-05-01,;,2021-06-01T10:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]time.Time{
+		{time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), {}},
+		{{}, time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(*expiries, expected) {
+		t.Errorf("expected %+v got %+v", expected, *expiries)
+	}
+
+	if _, err := ParseExpiries("2021-13-01"); err != InvalidArgument {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
+
+// A single expiry date over 24 buckets x 4 depth, the other products never expire
+func BenchmarkFindFreshPopPattern(b *testing.B) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	vendingMachine := randomVendingMachine(rand.New(rand.NewSource(1)), 24, 4, 8)
+	products := randomProducts(rand.New(rand.NewSource(2)), 24, 8)
+	expiries := make([][]time.Time, len(vendingMachine))
+	expiries[3] = []time.Time{now.AddDate(0, 0, 1)}
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := FindFreshPopPattern(&vendingMachine, &expiries, &products, now); err != nil && err != ImpossibleErr {
+			b.Fatal(err)
+		}
+	}
+}
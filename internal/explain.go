@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ProductLocation struct {
+	Bucket int
+	// Depths of the product in the bucket, 0 is the front
+	Depths []int
+	// Products in front of the first one that are not part of the order
+	Blockers []int
+}
+
+type ProductReport struct {
+	Product   int
+	Requested int
+	// Number of products in the whole vending machine
+	Available int
+	Locations []*ProductLocation
+	// Fewest products outside of the order that need to be popped to reach the
+	// requested quantity, -1 when the vending machine does not have enough
+	ExtraPops int
+}
+
+func (pr *ProductReport) Reason() string {
+	switch {
+	case pr.Available == 0:
+		return "missing"
+	case pr.Available < pr.Requested:
+		return "short"
+	case pr.ExtraPops > 0:
+		return "buried"
+	default:
+		return "reachable"
+	}
+}
+
+// ImpossibleError explains why an order can't be vended, by product in the order they
+// were first requested. It matches ImpossibleErr with errors.Is.
+type ImpossibleError struct {
+	Products []*ProductReport
+}
+
+func (e *ImpossibleError) Error() string {
+	return ImpossibleErr.Error()
+}
+
+func (e *ImpossibleError) Is(target error) bool {
+	return target == ImpossibleErr
+}
+
+func (e *ImpossibleError) Report() string {
+	var builder strings.Builder
+	for _, pr := range e.Products {
+		builder.WriteString(fmt.Sprintf(
+			"Product %d: %s, requested %d available %d", pr.Product, pr.Reason(), pr.Requested, pr.Available,
+		))
+		if pr.ExtraPops > 0 {
+			builder.WriteString(fmt.Sprintf(", needs %d extra pops", pr.ExtraPops))
+		}
+		builder.WriteString("\n")
+		for _, location := range pr.Locations {
+			builder.WriteString(fmt.Sprintf("\tbucket %d depths %+v", location.Bucket, location.Depths))
+			if len(location.Blockers) > 0 {
+				builder.WriteString(fmt.Sprintf(" blocked by %+v", location.Blockers))
+			}
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// Explain builds the report of every product in the order, whether the order is
+// possible or not, as some orders are only impossible because of how products share
+// buckets.
+func Explain(vendingMachine *[][]int, products *[]int) *ImpossibleError {
+	requested := map[int]int{}
+	var ordered []int
+	for _, product := range *products {
+		if requested[product] == 0 {
+			ordered = append(ordered, product)
+		}
+		requested[product]++
+	}
+
+	explained := &ImpossibleError{}
+	for _, product := range ordered {
+		explained.Products = append(explained.Products, explainProduct(vendingMachine, product, requested))
+	}
+
+	return explained
+}
+
+func explainProduct(vendingMachine *[][]int, product int, requested map[int]int) *ProductReport {
+	report := &ProductReport{
+		Product:   product,
+		Requested: requested[product],
+	}
+
+	// Extra pops needed to reach the first n products of every bucket
+	var bucketCosts [][]int
+
+	for i, bucket := range *vendingMachine {
+		location := &ProductLocation{Bucket: i}
+		costs := []int{0}
+		extra := 0
+
+		for depth, p := range bucket {
+			if p == product {
+				location.Depths = append(location.Depths, depth)
+				costs = append(costs, extra)
+				continue
+			}
+			if requested[p] == 0 {
+				extra++
+				if len(location.Depths) == 0 {
+					location.Blockers = append(location.Blockers, p)
+				}
+			}
+		}
+
+		if len(location.Depths) == 0 {
+			continue
+		}
+		report.Available += len(location.Depths)
+		report.Locations = append(report.Locations, location)
+		bucketCosts = append(bucketCosts, costs)
+	}
+
+	if report.Available < report.Requested {
+		report.ExtraPops = -1
+		return report
+	}
+	report.ExtraPops = fewestExtraPops(bucketCosts, report.Requested)
+
+	return report
+}
+
+// Splits the wanted quantity over the buckets so that the sum of extra pops is the
+// lowest, costs[b][n] being the extra pops for the first n products of bucket b.
+func fewestExtraPops(costs [][]int, wanted int) int {
+	const unreachable = -1
+
+	best := make([]int, wanted+1)
+	for i := 1; i <= wanted; i++ {
+		best[i] = unreachable
+	}
+
+	for _, bucketCosts := range costs {
+		next := make([]int, wanted+1)
+		copy(next, best)
+		for have := 0; have <= wanted; have++ {
+			if best[have] == unreachable {
+				continue
+			}
+			for n := 1; n < len(bucketCosts) && have+n <= wanted; n++ {
+				cost := best[have] + bucketCosts[n]
+				if next[have+n] == unreachable || cost < next[have+n] {
+					next[have+n] = cost
+				}
+			}
+		}
+		best = next
+	}
+
+	return best[wanted]
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 4, 9, 4},
+		{6, 6},
+		{9, 9, 4},
+	}
+	products := []int{4, 6, 7, 4, 4}
+
+	data := []struct {
+		scenario          string
+		product           int
+		expectedRequested int
+		expectedAvailable int
+		expectedExtraPops int
+		expectedReason    string
+		expectedLocations []*ProductLocation
+	}{
+		{
+			scenario:          "Buried",
+			product:           4,
+			expectedRequested: 3,
+			expectedAvailable: 3,
+			expectedExtraPops: 4,
+			expectedReason:    "buried",
+			expectedLocations: []*ProductLocation{
+				{Bucket: 0, Depths: []int{1, 3}, Blockers: []int{1}},
+				{Bucket: 2, Depths: []int{2}, Blockers: []int{9, 9}},
+			},
+		},
+		{
+			scenario:          "Reachable",
+			product:           6,
+			expectedRequested: 1,
+			expectedAvailable: 2,
+			expectedExtraPops: 0,
+			expectedReason:    "reachable",
+			expectedLocations: []*ProductLocation{
+				{Bucket: 1, Depths: []int{0, 1}},
+			},
+		},
+		{
+			scenario:          "Missing",
+			product:           7,
+			expectedRequested: 1,
+			expectedAvailable: 0,
+			expectedExtraPops: -1,
+			expectedReason:    "missing",
+		},
+	}
+
+	explained := Explain(&vendingMachine, &products)
+	if len(explained.Products) != len(data) {
+		t.Fatalf("Expected %d products, got %d", len(data), len(explained.Products))
+	}
+
+	for i, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			report := explained.Products[i]
+			if report.Product != d.product {
+				t.Fatalf("Expected product %d, got %d", d.product, report.Product)
+			}
+			if report.Requested != d.expectedRequested || report.Available != d.expectedAvailable {
+				t.Fatalf("Expected requested %d available %d, got %d %d",
+					d.expectedRequested, d.expectedAvailable, report.Requested, report.Available)
+			}
+			if report.ExtraPops != d.expectedExtraPops {
+				t.Fatalf("Expected extra pops %d, got %d", d.expectedExtraPops, report.ExtraPops)
+			}
+			if report.Reason() != d.expectedReason {
+				t.Fatalf("Expected reason %s, got %s", d.expectedReason, report.Reason())
+			}
+			if len(report.Locations) != len(d.expectedLocations) {
+				t.Fatalf("Expected %d locations, got %d", len(d.expectedLocations), len(report.Locations))
+			}
+			for j, location := range d.expectedLocations {
+				got := report.Locations[j]
+				if got.Bucket != location.Bucket ||
+					areEqualInt(got.Depths, location.Depths) == false ||
+					areEqualInt(got.Blockers, location.Blockers) == false {
+					t.Fatalf("Expected location %+v, got %+v", *location, *got)
+				}
+			}
+		})
+	}
+}
+
+func TestFindAndPopByOrder_Impossible(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 4},
+		{6, 6},
+	}
+	products := []int{4, 6}
+
+	err := FindAndPopByOrder(&vendingMachine, &products, FindBacktrackingPattern)
+	if !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+
+	var impossibleErr *ImpossibleError
+	if !errors.As(err, &impossibleErr) {
+		t.Fatal("Expected an explained error")
+	}
+	if len(impossibleErr.Products) != 2 || impossibleErr.Products[0].Reason() != "buried" {
+		t.Fatalf("Invalid report\n%s", impossibleErr.Report())
+	}
+}
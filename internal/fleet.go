@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Fleet is several vending machines side by side that can serve the same order
+type Fleet struct {
+	Machines []*[][]int
+}
+
+// CreateFleetFromStrings creates a vending machine from each encoding, in order.
+func CreateFleetFromStrings(strs []string) (*Fleet, error) {
+	fleet := &Fleet{Machines: make([]*[][]int, 0, len(strs))}
+	for _, str := range strs {
+		vendingMachine, err := CreateFromString(str)
+		if err != nil {
+			return nil, err
+		}
+		fleet.Machines = append(fleet.Machines, vendingMachine)
+	}
+
+	return fleet, nil
+}
+
+// FleetPattern is the part of the order served by one machine of the fleet
+type FleetPattern struct {
+	Machine  int
+	Products []int
+	Patterns *[]*PopPattern
+}
+
+func (fp *FleetPattern) Print() {
+	fmt.Printf("[FleetPattern]: Machine %d Products %+v Patterns %s\n", fp.Machine, fp.Products, patternsString(*fp.Patterns))
+}
+
+// The order split over the machines from index i onward, using at most the given
+// number of them. Splits that failed are remembered by machine and products left.
+type fleetSearch struct {
+	fleet  *Fleet
+	fn     PatternFunc
+	failed map[string]bool
+}
+
+func (s *fleetSearch) split(i int, products []int, machines int) []*FleetPattern {
+	if len(products) == 0 {
+		return []*FleetPattern{}
+	}
+	if machines == 0 || i == len(s.fleet.Machines) {
+		return nil
+	}
+
+	key := fmt.Sprintf("%d:%d:%v", i, machines, products)
+	if s.failed[key] {
+		return nil
+	}
+
+	for _, served := range subOrders(products) {
+		patterns, err := FindCumulativePopPattern(s.fleet.Machines[i], &served, s.fn)
+		if err != nil || patterns == nil {
+			continue
+		}
+		rest := s.split(i+1, removeProducts(products, served), machines-1)
+		if rest == nil {
+			continue
+		}
+
+		return append([]*FleetPattern{{Machine: i, Products: served, Patterns: patterns}}, rest...)
+	}
+
+	// The machine can also serve nothing
+	if rest := s.split(i+1, products, machines); rest != nil {
+		return rest
+	}
+
+	s.failed[key] = true
+
+	return nil
+}
+
+// Every non empty part of the order, largest first, keeping the products in the
+// order they were asked for.
+func subOrders(products []int) [][]int {
+	counts := map[int]int{}
+	distinct := []int{}
+	for _, product := range products {
+		if counts[product] == 0 {
+			distinct = append(distinct, product)
+		}
+		counts[product]++
+	}
+
+	subs := [][]int{}
+	taken := make([]int, len(distinct))
+	var walk func(k int)
+	walk = func(k int) {
+		if k == len(distinct) {
+			limits := map[int]int{}
+			for j, product := range distinct {
+				limits[product] = taken[j]
+			}
+			sub := []int{}
+			for _, product := range products {
+				if limits[product] > 0 {
+					limits[product]--
+					sub = append(sub, product)
+				}
+			}
+			if len(sub) > 0 {
+				subs = append(subs, sub)
+			}
+			return
+		}
+		for taken[k] = counts[distinct[k]]; taken[k] >= 0; taken[k]-- {
+			walk(k + 1)
+		}
+	}
+	walk(0)
+
+	sort.SliceStable(subs, func(a, b int) bool {
+		return len(subs[a]) > len(subs[b])
+	})
+
+	return subs
+}
+
+func removeProducts(products []int, removed []int) []int {
+	left := map[int]int{}
+	for _, product := range removed {
+		left[product]++
+	}
+
+	rest := []int{}
+	for _, product := range products {
+		if left[product] > 0 {
+			left[product]--
+			continue
+		}
+		rest = append(rest, product)
+	}
+
+	return rest
+}
+
+// FindFleetPopPattern finds the machine that serves the whole order, the first one in
+// the fleet when several can. When no single machine can and split is allowed, the
+// order is split over the fewest machines possible.
+func FindFleetPopPattern(fleet *Fleet, products *[]int, fn PatternFunc, split bool) (*[]*FleetPattern, error) {
+	for i, vendingMachine := range fleet.Machines {
+		patterns, err := FindCumulativePopPattern(vendingMachine, products, fn)
+		if err != nil || patterns == nil {
+			continue
+		}
+
+		return &[]*FleetPattern{{
+			Machine:  i,
+			Products: append([]int{}, *products...),
+			Patterns: patterns,
+		}}, nil
+	}
+
+	if split == false {
+		return nil, ImpossibleErr
+	}
+
+	search := &fleetSearch{fleet: fleet, fn: fn, failed: map[string]bool{}}
+	for machines := 2; machines <= len(fleet.Machines); machines++ {
+		if fleetPatterns := search.split(0, *products, machines); fleetPatterns != nil {
+			return &fleetPatterns, nil
+		}
+	}
+
+	return nil, ImpossibleErr
+}
+
+func FindAndPopFleet(fleet *Fleet, products *[]int, fn PatternFunc, split bool) (*[]*FleetPattern, error) {
+	fleetPatterns, err := FindFleetPopPattern(fleet, products, fn, split)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fleetPattern := range *fleetPatterns {
+		PopByPattern(fleet.Machines[fleetPattern.Machine], fleetPattern.Patterns)
+	}
+
+	return fleetPatterns, nil
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFindAndPopFleet(t *testing.T) {
+	data := []struct {
+		scenario         string
+		machines         []string
+		products         []int
+		split            bool
+		expectedPatterns []*FleetPattern
+		expectedMachines [][][]int
+		expectedErr      error
+	}{
+		{
+			scenario: "First machine that serves the whole order",
+			machines: []string{"1;3", "1,2;3", "2,1;3"},
+			products: []int{1, 2},
+			expectedPatterns: []*FleetPattern{
+				{Machine: 1, Products: []int{1, 2}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 2}}},
+			},
+			expectedMachines: [][][]int{
+				{{1}, {3}},
+				{{}, {3}},
+				{{2, 1}, {3}},
+			},
+		},
+		{
+			scenario: "Single machine is preferred over a split",
+			machines: []string{"1", "2", "1,2"},
+			products: []int{1, 2},
+			split:    true,
+			expectedPatterns: []*FleetPattern{
+				{Machine: 2, Products: []int{1, 2}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 2}}},
+			},
+			expectedMachines: [][][]int{
+				{{1}},
+				{{2}},
+				{{}},
+			},
+		},
+		{
+			scenario:    "Split is not allowed",
+			machines:    []string{"1", "2"},
+			products:    []int{1, 2},
+			expectedErr: ImpossibleErr,
+			expectedMachines: [][][]int{
+				{{1}},
+				{{2}},
+			},
+		},
+		{
+			scenario: "Split over two machines",
+			machines: []string{"1,1", "3", "2,3"},
+			products: []int{1, 2, 3},
+			split:    true,
+			expectedPatterns: []*FleetPattern{
+				{Machine: 0, Products: []int{1}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 1}}},
+				{Machine: 2, Products: []int{2, 3}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 2}}},
+			},
+			expectedMachines: [][][]int{
+				{{1}},
+				{{3}},
+				{{}},
+			},
+		},
+		{
+			scenario: "Split over every machine",
+			machines: []string{"1", "2", "3"},
+			products: []int{3, 2, 1},
+			split:    true,
+			expectedPatterns: []*FleetPattern{
+				{Machine: 0, Products: []int{1}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 1}}},
+				{Machine: 1, Products: []int{2}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 1}}},
+				{Machine: 2, Products: []int{3}, Patterns: &[]*PopPattern{{Index: 0, NumberPopped: 1}}},
+			},
+			expectedMachines: [][][]int{
+				{{}},
+				{{}},
+				{{}},
+			},
+		},
+		{
+			scenario:    "Impossible even when split",
+			machines:    []string{"1", "2"},
+			products:    []int{1, 1, 2},
+			split:       true,
+			expectedErr: ImpossibleErr,
+			expectedMachines: [][][]int{
+				{{1}},
+				{{2}},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			fleet, err := CreateFleetFromStrings(d.machines)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fleetPatterns, err := FindAndPopFleet(fleet, &d.products, FindBacktrackingPattern, d.split)
+			if err != d.expectedErr {
+				t.Fatalf("expected error %v got %v", d.expectedErr, err)
+			}
+			if err == nil {
+				if len(*fleetPatterns) != len(d.expectedPatterns) {
+					t.Fatalf("expected %d fleet patterns got %d", len(d.expectedPatterns), len(*fleetPatterns))
+				}
+				for i, fleetPattern := range *fleetPatterns {
+					expected := d.expectedPatterns[i]
+					if fleetPattern.Machine != expected.Machine || !reflect.DeepEqual(fleetPattern.Products, expected.Products) {
+						t.Errorf("expected machine %d products %+v got machine %d products %+v",
+							expected.Machine, expected.Products, fleetPattern.Machine, fleetPattern.Products)
+					}
+					if err := assertEqualPatterns(fleetPattern.Patterns, expected.Patterns); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+
+			for i, vendingMachine := range fleet.Machines {
+				if !reflect.DeepEqual(*vendingMachine, d.expectedMachines[i]) {
+					t.Errorf("expected machine %d to be %+v got %+v", i, d.expectedMachines[i], *vendingMachine)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateFleetFromStrings(t *testing.T) {
+	if _, err := CreateFleetFromStrings([]string{"1,2", "1,x"}); !errors.Is(err, InvalidArgument) {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var HoldNotFoundErr = errors.New("hold not found")
+var HoldExpiredErr = errors.New("hold has expired")
+var HeldBucketErr = errors.New("bucket is held for an order")
+var HeldProductsMissingErr = errors.New("held products are no longer in the vending machine")
+var HeldProductsExpiredErr = errors.New("held products have expired")
+
+// Hold keeps the pop patterns of an order until it is picked up. Held products are at
+// the front of their buckets, so the buckets of a hold can't vend anything else.
+type Hold struct {
+	Id       int
+	Products []int
+	Patterns []*PopPattern
+	// Zero time when the hold does not expire
+	Expires time.Time
+}
+
+func (h *Hold) Print() {
+	fmt.Printf("[Hold]: Id %d Products %+v Patterns %s Expires %s\n",
+		h.Id, h.Products, patternsString(h.Patterns), h.Expires.Format(time.RFC3339))
+}
+
+func (h *Hold) isExpired(now time.Time) bool {
+	return isExpired(h.Expires, now)
+}
+
+// Holds of a vending machine by id, numbered from 1 in the order they were made
+type Holds struct {
+	holds  map[int]*Hold
+	nextId int
+}
+
+func NewHolds() *Holds {
+	return &Holds{
+		holds:  map[int]*Hold{},
+		nextId: 1,
+	}
+}
+
+func (h *Holds) Copy() *Holds {
+	copied := &Holds{holds: make(map[int]*Hold, len(h.holds)), nextId: h.nextId}
+	for id, hold := range h.holds {
+		copied.holds[id] = &Hold{
+			Id:       hold.Id,
+			Products: append([]int{}, hold.Products...),
+			Patterns: copyPatterns(hold.Patterns),
+			Expires:  hold.Expires,
+		}
+	}
+
+	return copied
+}
+
+// Get returns the hold, false when there is none with the id.
+func (h *Holds) Get(id int) (*Hold, bool) {
+	hold, ok := h.holds[id]
+	return hold, ok
+}
+
+// List returns the holds by id
+func (h *Holds) List() []*Hold {
+	holds := make([]*Hold, 0, len(h.holds))
+	for _, hold := range h.holds {
+		holds = append(holds, hold)
+	}
+	sort.Slice(holds, func(i, j int) bool {
+		return holds[i].Id < holds[j].Id
+	})
+
+	return holds
+}
+
+// Buckets that have held products
+func (h *Holds) held() map[int]bool {
+	held := map[int]bool{}
+	for _, hold := range h.holds {
+		for _, pattern := range hold.Patterns {
+			if pattern.NumberPopped > 0 {
+				held[pattern.Index] = true
+			}
+		}
+	}
+
+	return held
+}
+
+// IsHeld tells whether the bucket has held products.
+func (h *Holds) IsHeld(bucket int) bool {
+	return h.held()[bucket]
+}
+
+// CheckNotHeld returns HeldBucketErr when one of the patterns pops a bucket that has
+// held products.
+func (h *Holds) CheckNotHeld(patterns *[]*PopPattern) error {
+	held := h.held()
+	for _, pattern := range *patterns {
+		if pattern.NumberPopped > 0 && held[pattern.Index] {
+			return HeldBucketErr
+		}
+	}
+
+	return nil
+}
+
+// Expire removes the holds that expired by now, returning them by id.
+func (h *Holds) Expire(now time.Time) []*Hold {
+	expired := []*Hold{}
+	for _, hold := range h.List() {
+		if hold.isExpired(now) {
+			delete(h.holds, hold.Id)
+			expired = append(expired, hold)
+		}
+	}
+
+	return expired
+}
+
+func (h *Holds) limits(vendingMachine *[][]int) []int {
+	held := h.held()
+	limits := make([]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		if !held[i] {
+			limits[i] = len(bucket)
+		}
+	}
+
+	return limits
+}
+
+// Available is the vending machine as the pattern functions see it, with the buckets
+// that have held products empty. Holds that expired by now are removed first.
+func (h *Holds) Available(vendingMachine *[][]int, now time.Time) *[][]int {
+	h.Expire(now)
+
+	return limitedView(vendingMachine, h.limits(vendingMachine))
+}
+
+// WithHolds runs fn on the buckets without held products and pops from the vending
+// machine what fn popped. Holds that expired by now are removed first.
+func (h *Holds) WithHolds(vendingMachine *[][]int, now time.Time, fn func(view *[][]int) error) error {
+	h.Expire(now)
+
+	return withLimits(vendingMachine, h.limits(vendingMachine), fn)
+}
+
+// FindAndPopByOrder is FindAndPopByOrder leaving the held products where they are.
+func (h *Holds) FindAndPopByOrder(vendingMachine *[][]int, products *[]int, fn PatternFunc, now time.Time) error {
+	return h.WithHolds(vendingMachine, now, func(view *[][]int) error {
+		return FindAndPopByOrder(view, products, fn)
+	})
+}
+
+// Reserve holds the products the patterns pop for the order, after checking that the
+// patterns pop the products of the order from buckets that are not held.
+func (h *Holds) Reserve(vendingMachine *[][]int, products *[]int, patterns *[]*PopPattern, expires time.Time, now time.Time) (*Hold, error) {
+	h.Expire(now)
+	if err := h.CheckNotHeld(patterns); err != nil {
+		return nil, err
+	}
+	if err := checkPopsProducts(vendingMachine, products, patterns); err != nil {
+		return nil, err
+	}
+
+	hold := &Hold{
+		Id:       h.nextId,
+		Products: append([]int{}, *products...),
+		Patterns: copyPatterns(*patterns),
+		Expires:  expires,
+	}
+	h.holds[hold.Id] = hold
+	h.nextId++
+
+	return hold, nil
+}
+
+// ReserveOrder finds the pop pattern like FindCumulativePopPattern does, on the buckets
+// without held products, and reserves it.
+func (h *Holds) ReserveOrder(vendingMachine *[][]int, products *[]int, fn PatternFunc, expires time.Time, now time.Time) (*Hold, error) {
+	available := h.Available(vendingMachine, now)
+	patterns, err := FindCumulativePopPattern(available, products, fn)
+	if err == ImpossibleErr {
+		return nil, Explain(available, products)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Reserve(vendingMachine, products, patterns, expires, now)
+}
+
+// Release drops the hold, its products can be vended again.
+func (h *Holds) Release(id int) error {
+	if _, ok := h.holds[id]; !ok {
+		return HoldNotFoundErr
+	}
+	delete(h.holds, id)
+
+	return nil
+}
+
+// Pending returns the hold that can still be fulfilled, an expired hold is dropped
+// returning HoldExpiredErr.
+func (h *Holds) Pending(id int, now time.Time) (*Hold, error) {
+	hold, ok := h.holds[id]
+	if !ok {
+		return nil, HoldNotFoundErr
+	}
+	if hold.isExpired(now) {
+		delete(h.holds, id)
+		return nil, HoldExpiredErr
+	}
+
+	return hold, nil
+}
+
+// Fulfil pops the saved patterns of the hold and drops it. An expired hold is dropped
+// without popping anything, and a hold whose products were taken out some other way
+// is kept so that it can be released.
+func (h *Holds) Fulfil(vendingMachine *[][]int, id int, now time.Time) (*Hold, error) {
+	hold, err := h.Pending(id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPopsProducts(vendingMachine, &hold.Products, &hold.Patterns); err != nil {
+		return nil, HeldProductsMissingErr
+	}
+	patterns := copyPatterns(hold.Patterns)
+	PopByPattern(vendingMachine, &patterns)
+	delete(h.holds, id)
+
+	return hold, nil
+}
+
+// The patterns must pop exactly the products of the order
+func checkPopsProducts(vendingMachine *[][]int, products *[]int, patterns *[]*PopPattern) error {
+	steps, err := PatternsToSteps(vendingMachine, patterns)
+	if err != nil {
+		return err
+	}
+
+	popped := make([]int, 0, len(*steps))
+	for _, step := range *steps {
+		popped = append(popped, step.Product)
+	}
+	if !sameProducts(popped, *products) {
+		return InvalidArgument
+	}
+
+	return nil
+}
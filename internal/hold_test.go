@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHolds(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	vendingMachine := [][]int{{1, 2}, {1, 3}, {2}}
+	holds := NewHolds()
+
+	hold, err := holds.ReserveOrder(&vendingMachine, &[]int{1}, FindBacktrackingPattern, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assertEqualPatterns(&hold.Patterns, &[]*PopPattern{{Index: 0, NumberPopped: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The held bucket can't be popped past its held product
+	if err := holds.FindAndPopByOrder(&vendingMachine, &[]int{2}, FindBacktrackingPattern, now); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vendingMachine, [][]int{{1, 2}, {1, 3}, {}}) {
+		t.Fatalf("unexpected vending machine %+v", vendingMachine)
+	}
+	if err := holds.FindAndPopByOrder(&vendingMachine, &[]int{2}, FindBacktrackingPattern, now); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("expected %v got %v", ImpossibleErr, err)
+	}
+
+	expiring, err := holds.ReserveOrder(&vendingMachine, &[]int{1}, FindBacktrackingPattern, now.Add(time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := holds.ReserveOrder(&vendingMachine, &[]int{1}, FindBacktrackingPattern, time.Time{}, now); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("expected %v got %v", ImpossibleErr, err)
+	}
+	if _, err := holds.Reserve(&vendingMachine, &[]int{1}, &[]*PopPattern{{Index: 1, NumberPopped: 1}}, time.Time{}, now); err != HeldBucketErr {
+		t.Fatalf("expected %v got %v", HeldBucketErr, err)
+	}
+
+	// Expired holds make their products available again
+	later := now.Add(2 * time.Hour)
+	if _, err := holds.Fulfil(&vendingMachine, expiring.Id, later); err != HoldExpiredErr {
+		t.Fatalf("expected %v got %v", HoldExpiredErr, err)
+	}
+	if _, ok := holds.Get(expiring.Id); ok {
+		t.Fatal("expected the expired hold to be dropped")
+	}
+	if err := holds.FindAndPopByOrder(&vendingMachine, &[]int{1, 3}, FindBacktrackingPattern, later); err != nil {
+		t.Fatal(err)
+	}
+
+	fulfilled, err := holds.Fulfil(&vendingMachine, hold.Id, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fulfilled.Products, []int{1}) {
+		t.Fatalf("unexpected products %+v", fulfilled.Products)
+	}
+	if !reflect.DeepEqual(vendingMachine, [][]int{{2}, {}, {}}) {
+		t.Fatalf("unexpected vending machine %+v", vendingMachine)
+	}
+	if _, err := holds.Fulfil(&vendingMachine, hold.Id, later); err != HoldNotFoundErr {
+		t.Fatalf("expected %v got %v", HoldNotFoundErr, err)
+	}
+	if len(holds.List()) != 0 {
+		t.Fatalf("expected no holds got %+v", holds.List())
+	}
+}
+
+func TestHolds_Release(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	vendingMachine := [][]int{{1, 2}}
+	holds := NewHolds()
+
+	hold, err := holds.ReserveOrder(&vendingMachine, &[]int{1}, FindBacktrackingPattern, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holds.Release(hold.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := holds.Release(hold.Id); err != HoldNotFoundErr {
+		t.Fatalf("expected %v got %v", HoldNotFoundErr, err)
+	}
+	if err := holds.FindAndPopByOrder(&vendingMachine, &[]int{2, 1}, FindBacktrackingPattern, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHolds_FulfilMissingProducts(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	vendingMachine := [][]int{{1, 2}}
+	holds := NewHolds()
+
+	hold, err := holds.Reserve(&vendingMachine, &[]int{1}, &[]*PopPattern{{Index: 0, NumberPopped: 1}}, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := holds.Reserve(&vendingMachine, &[]int{1}, &[]*PopPattern{{Index: 0, NumberPopped: 2}}, time.Time{}, now); err != HeldBucketErr {
+		t.Fatalf("expected %v got %v", HeldBucketErr, err)
+	}
+
+	PopByPattern(&vendingMachine, &[]*PopPattern{{Index: 0, NumberPopped: 1}})
+	if _, err := holds.Fulfil(&vendingMachine, hold.Id, now); err != HeldProductsMissingErr {
+		t.Fatalf("expected %v got %v", HeldProductsMissingErr, err)
+	}
+	if _, ok := holds.Get(hold.Id); !ok {
+		t.Fatal("expected the hold to be kept")
+	}
+}
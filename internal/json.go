@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON schemas of the documents, by name as accepted on the command line
+var Schemas = map[string]string{
+	"machine": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Vending machine",
+  "type": "object",
+  "required": ["buckets"],
+  "additionalProperties": false,
+  "properties": {
+    "buckets": {
+      "description": "Product ids of each bucket, front first",
+      "type": "array",
+      "items": {"type": "array", "items": {"type": "integer"}}
+    },
+    "capacities": {
+      "description": "Most products of each bucket, 0 for no limit",
+      "type": "array",
+      "items": {"type": "integer", "minimum": 0}
+    },
+    "statuses": {
+      "description": "Status of each bucket, missing ones are active",
+      "type": "array",
+      "items": {"enum": ["active", "jammed", "maintenance"]}
+    },
+    "metadata": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`,
+	"order": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Order",
+  "type": "object",
+  "required": ["products"],
+  "additionalProperties": false,
+  "properties": {
+    "products": {"type": "array", "items": {"type": "integer"}}
+  }
+}`,
+	"plan": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Plan",
+  "type": "object",
+  "required": ["pops"],
+  "additionalProperties": false,
+  "properties": {
+    "pops": {
+      "description": "Pops in the order they are made",
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["bucket", "count"],
+        "additionalProperties": false,
+        "properties": {
+          "bucket": {"type": "integer", "minimum": 0},
+          "count": {"type": "integer", "minimum": 0}
+        }
+      }
+    }
+  }
+}`,
+}
+
+// MachineDocument is the vending machine as JSON, with what it knows besides the
+// buckets.
+type MachineDocument struct {
+	Buckets    [][]int           `json:"buckets"`
+	Capacities []int             `json:"capacities,omitempty"`
+	Statuses   []BucketStatus    `json:"statuses,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+type orderDocument struct {
+	Products []int `json:"products"`
+}
+
+type popDocument struct {
+	Bucket int `json:"bucket"`
+	Count  int `json:"count"`
+}
+
+type planDocument struct {
+	Pops []popDocument `json:"pops"`
+}
+
+// Decodes the document, refusing unknown fields and anything after it.
+func decodeJSON(data []byte, document interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(document); err != nil {
+		return fmt.Errorf("%w: %v", InvalidArgument, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: unexpected data after the document", InvalidArgument)
+	}
+
+	return nil
+}
+
+func NewMachineDocument(vendingMachine *[][]int) *MachineDocument {
+	return &MachineDocument{Buckets: *CopyVendingMachine(vendingMachine)}
+}
+
+// MachineFromJSON decodes the vending machine, checking that there is no capacity or
+// status for buckets it does not have.
+func MachineFromJSON(data []byte) (*MachineDocument, error) {
+	document := &MachineDocument{}
+	if err := decodeJSON(data, document); err != nil {
+		return nil, err
+	}
+	if document.Buckets == nil {
+		return nil, fmt.Errorf("%w: missing buckets", InvalidArgument)
+	}
+	if len(document.Capacities) > len(document.Buckets) || len(document.Statuses) > len(document.Buckets) {
+		return nil, fmt.Errorf("%w: more capacities or statuses than buckets", InvalidArgument)
+	}
+	for i, capacity := range document.Capacities {
+		if capacity < 0 {
+			return nil, fmt.Errorf("%w: negative capacity of bucket %d", InvalidArgument, i)
+		}
+	}
+	for i, bucket := range document.Buckets {
+		if bucket == nil {
+			document.Buckets[i] = []int{}
+		}
+	}
+
+	return document, nil
+}
+
+func (d *MachineDocument) VendingMachine() *[][]int {
+	return CopyVendingMachine(&d.Buckets)
+}
+
+func (d *MachineDocument) JSON() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func OrderToJSON(products *[]int) ([]byte, error) {
+	return json.Marshal(orderDocument{Products: append([]int{}, *products...)})
+}
+
+func OrderFromJSON(data []byte) (*[]int, error) {
+	document := &orderDocument{}
+	if err := decodeJSON(data, document); err != nil {
+		return nil, err
+	}
+	if document.Products == nil {
+		return nil, fmt.Errorf("%w: missing products", InvalidArgument)
+	}
+
+	return &document.Products, nil
+}
+
+func PlanToJSON(patterns *[]*PopPattern) ([]byte, error) {
+	document := planDocument{Pops: make([]popDocument, 0, len(*patterns))}
+	for _, pattern := range *patterns {
+		document.Pops = append(document.Pops, popDocument{Bucket: pattern.Index, Count: pattern.NumberPopped})
+	}
+
+	return json.Marshal(document)
+}
+
+func PlanFromJSON(data []byte) (*[]*PopPattern, error) {
+	document := &planDocument{}
+	if err := decodeJSON(data, document); err != nil {
+		return nil, err
+	}
+	if document.Pops == nil {
+		return nil, fmt.Errorf("%w: missing pops", InvalidArgument)
+	}
+
+	patterns := make([]*PopPattern, 0, len(document.Pops))
+	for _, pop := range document.Pops {
+		if pop.Bucket < 0 || pop.Count < 0 {
+			return nil, fmt.Errorf("%w: negative bucket or count", InvalidArgument)
+		}
+		patterns = append(patterns, &PopPattern{Index: pop.Bucket, NumberPopped: pop.Count})
+	}
+
+	return &patterns, nil
+}
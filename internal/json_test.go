@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMachineJSON_RoundTrip(t *testing.T) {
+	data := []struct {
+		scenario     string
+		str          string
+		expectedJSON string
+	}{
+		{scenario: "Example", str: "1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1", expectedJSON: `{"buckets":[[1,2,3,5,5],[2,5,4,3,1],[3,5,4,1,1],[5,1,1,1,1]]}`},
+		{scenario: "Single bucket", str: "7", expectedJSON: `{"buckets":[[7]]}`},
+		{scenario: "No buckets", str: "", expectedJSON: `{"buckets":[]}`},
+		{scenario: "Negative products", str: "-1,2;3", expectedJSON: `{"buckets":[[-1,2],[3]]}`},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine, err := CreateFromString(d.str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := NewMachineDocument(vendingMachine).JSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(encoded) != d.expectedJSON {
+				t.Errorf("expected %s got %s", d.expectedJSON, encoded)
+			}
+
+			document, err := MachineFromJSON(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(document.VendingMachine(), vendingMachine) {
+				t.Errorf("expected %+v got %+v", *vendingMachine, *document.VendingMachine())
+			}
+		})
+	}
+}
+
+func TestMachineFromJSON(t *testing.T) {
+	document, err := MachineFromJSON([]byte(`{
+		"buckets": [[1, 2], [], [3]],
+		"capacities": [5, 0],
+		"statuses": ["active", "jammed"],
+		"metadata": {"site": "lobby"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &MachineDocument{
+		Buckets:    [][]int{{1, 2}, {}, {3}},
+		Capacities: []int{5, 0},
+		Statuses:   []BucketStatus{Active, Jammed},
+		Metadata:   map[string]string{"site": "lobby"},
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("expected %+v got %+v", expected, document)
+	}
+
+	encoded, err := document.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedJSON := `{"buckets":[[1,2],[],[3]],"capacities":[5,0],"statuses":["active","jammed"],"metadata":{"site":"lobby"}}`
+	if string(encoded) != expectedJSON {
+		t.Errorf("expected %s got %s", expectedJSON, encoded)
+	}
+
+	for _, invalid := range []string{
+		`{}`,
+		`{"buckets": [[1, "a"]]}`,
+		`{"buckets": [[1]], "unknown": 1}`,
+		`{"buckets": [[1]], "statuses": ["broken"]}`,
+		`{"buckets": [[1]], "statuses": ["active", "jammed"]}`,
+		`{"buckets": [[1]], "capacities": [-1]}`,
+		`{"buckets": [[1]]} {}`,
+	} {
+		if _, err := MachineFromJSON([]byte(invalid)); !errors.Is(err, InvalidArgument) {
+			t.Errorf("expected %s to be invalid got %v", invalid, err)
+		}
+	}
+}
+
+func TestOrderJSON_RoundTrip(t *testing.T) {
+	products, err := ParseInput("1,2,3,4,5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := OrderToJSON(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"products":[1,2,3,4,5]}` {
+		t.Errorf("unexpected order %s", encoded)
+	}
+
+	decoded, err := OrderFromJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, products) {
+		t.Errorf("expected %+v got %+v", *products, *decoded)
+	}
+
+	if _, err := OrderFromJSON([]byte(`[1, 2]`)); !errors.Is(err, InvalidArgument) {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
+
+func TestPlanJSON_RoundTrip(t *testing.T) {
+	vendingMachine, err := CreateFromString("1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := FindCumulativePopPattern(vendingMachine, &[]int{1, 2, 3, 4, 5}, FindBacktrackingPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := PlanToJSON(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"pops":[{"bucket":0,"count":1},{"bucket":1,"count":4}]}` {
+		t.Errorf("unexpected plan %s", encoded)
+	}
+
+	decoded, err := PlanFromJSON(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assertEqualPatterns(decoded, patterns); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := PlanFromJSON([]byte(`{"pops": [{"bucket": -1, "count": 1}]}`)); !errors.Is(err, InvalidArgument) {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for name, schema := range Schemas {
+		if !json.Valid([]byte(schema)) {
+			t.Errorf("schema %s is not valid JSON", name)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+// Whether the remaining products can be popped from bucket index i onward. As buckets
+// are decided one after another, the state is the bucket index with the remaining
+// products, since every bucket before it has its pop offset decided and every bucket
+// after it is untouched. Different choices often lead to the same state, so the
+// states that failed are remembered instead of being searched again.
+func (s *noOrderSearch) solve(i int, failed map[string]bool) bool {
+	if s.left == 0 {
+		return true
+	}
+	if i == len(s.buckets) || !s.feasible(i) {
+		return false
+	}
+
+	key := s.key(i)
+	if failed[key] {
+		return false
+	}
+
+	values := s.buckets[i].Values
+
+	taken := 0
+	for taken < len(values) && s.remaining[values[taken]] > 0 {
+		s.remaining[values[taken]]--
+		s.left--
+		taken++
+	}
+
+	for numberPopped := taken; numberPopped >= 0; numberPopped-- {
+		s.popped[i] = numberPopped
+		if s.solve(i+1, failed) {
+			return true
+		}
+		if numberPopped > 0 {
+			s.remaining[values[numberPopped-1]]++
+			s.left++
+		}
+	}
+	s.popped[i] = 0
+	failed[key] = true
+
+	return false
+}
+
+// FindMemoizedPattern is FindBacktrackingPattern that remembers the failed states,
+// which keeps deep orders over many buckets from searching the same state twice.
+// Order of products does not matter.
+func FindMemoizedPattern(possibleSlice *[]*PossibleBucketSlice, products *[]int) *[]*PopPattern {
+	search := newNoOrderSearch(*possibleSlice, *products)
+	if !search.solve(0, map[string]bool{}) {
+		return nil
+	}
+
+	return search.patterns()
+}
+
+// FindMemoizedPopPattern scans the vending machine once and searches all of the buckets
+// at once, rather than calling the pattern function for every growing number of buckets
+// like FindCumulativePopPattern does.
+// Order of products does not matter.
+func FindMemoizedPopPattern(vendingMachine *[][]int, products *[]int) (*[]*PopPattern, error) {
+	possibleSlices := scanPossibleSlices(vendingMachine, products)
+
+	patterns := FindMemoizedPattern(&possibleSlices, products)
+	if patterns == nil {
+		return nil, ImpossibleErr
+	}
+
+	return toMachinePatterns(possibleSlices, patterns), nil
+}
+
+func FindAndPopMemoized(vendingMachine *[][]int, products *[]int) error {
+	patterns, err := FindMemoizedPopPattern(vendingMachine, products)
+	if err == ImpossibleErr {
+		return Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	PopByPattern(vendingMachine, patterns)
+
+	return nil
+}
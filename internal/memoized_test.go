@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFindMemoizedPopPattern(t *testing.T) {
+	data := []struct {
+		scenario         string
+		vendingMachine   [][]int
+		products         []int
+		expectedPatterns *[]*PopPattern
+	}{
+		{
+			scenario: "Readme example",
+			vendingMachine: [][]int{
+				{1, 2, 3, 5, 5},
+				{2, 5, 4, 3, 1},
+				{3, 5, 4, 1, 1},
+				{5, 1, 1, 1, 1},
+			},
+			products: []int{1, 2, 3, 4, 5},
+			expectedPatterns: &[]*PopPattern{
+				{Index: 0, NumberPopped: 2},
+				{Index: 2, NumberPopped: 3},
+			},
+		},
+		{
+			scenario: "Skipped bucket keeps its index",
+			vendingMachine: [][]int{
+				{9, 1},
+				{3},
+				{1, 3},
+			},
+			products: []int{1, 3},
+			expectedPatterns: &[]*PopPattern{
+				{Index: 1, NumberPopped: 1},
+				{Index: 2, NumberPopped: 1},
+			},
+		},
+		{
+			scenario: "Impossible",
+			vendingMachine: [][]int{
+				{9, 1},
+				{3},
+			},
+			products:         []int{1, 3},
+			expectedPatterns: nil,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			patterns, err := FindMemoizedPopPattern(&d.vendingMachine, &d.products)
+			if d.expectedPatterns == nil {
+				if err != ImpossibleErr {
+					t.Fatalf("Expected impossible, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := assertEqualPatterns(patterns, d.expectedPatterns); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFindMemoizedPopPattern_SameAsBacktracking(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 1000; n++ {
+		vendingMachine := randomVendingMachine(random, 1+random.Intn(5), 3, 4)
+		products := randomProducts(random, 1+random.Intn(5), 4)
+
+		_, backtrackingErr := FindCumulativePopPattern(&vendingMachine, &products, FindBacktrackingPattern)
+		_, memoizedErr := FindMemoizedPopPattern(&vendingMachine, &products)
+		if backtrackingErr != memoizedErr {
+			t.Fatalf("Vending machine %+v products %+v: expected %v got %v",
+				vendingMachine, products, backtrackingErr, memoizedErr)
+		}
+	}
+}
+
+func randomVendingMachine(random *rand.Rand, buckets int, depth int, productIds int) [][]int {
+	vendingMachine := make([][]int, buckets)
+	for i := range vendingMachine {
+		vendingMachine[i] = randomProducts(random, depth, productIds)
+	}
+
+	return vendingMachine
+}
+
+func randomProducts(random *rand.Rand, length int, productIds int) []int {
+	products := make([]int, length)
+	for i := range products {
+		products[i] = 1 + random.Intn(productIds)
+	}
+
+	return products
+}
+
+// 1,000 buckets x 100 depth with 10 different products
+func benchmarkVendingMachine() [][]int {
+	return randomVendingMachine(rand.New(rand.NewSource(1)), 1000, 100, 10)
+}
+
+// Every bucket starts with 1 2 followed by other products, so each 2 takes a 1 with it. Asking for more 2s than
+// 1s is impossible, even though the vending machine has plenty of both.
+func benchmarkImpossibleVendingMachine() [][]int {
+	vendingMachine := benchmarkVendingMachine()
+	for _, bucket := range vendingMachine {
+		for i := range bucket {
+			if bucket[i] < 3 {
+				bucket[i] = 3
+			}
+		}
+		bucket[0] = 1
+		bucket[1] = 2
+	}
+
+	return vendingMachine
+}
+
+func BenchmarkFindMemoizedPopPattern(b *testing.B) {
+	vendingMachine := benchmarkVendingMachine()
+	products := randomProducts(rand.New(rand.NewSource(2)), 100, 10)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := FindMemoizedPopPattern(&vendingMachine, &products); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindMemoizedPopPattern_Impossible(b *testing.B) {
+	vendingMachine := benchmarkImpossibleVendingMachine()
+	products := []int{1, 1, 2, 2, 2}
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := FindMemoizedPopPattern(&vendingMachine, &products); err != ImpossibleErr {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package internal
+
+import "sort"
+
+// CostFunc scores a pop pattern, the lower the cost the better the pattern.
+// Pattern indexes refer to the buckets of the vending machine.
+type CostFunc func(vendingMachine *[][]int, patterns *[]*PopPattern) int
+
+// BucketCostFunc scores popping numberPopped products from the front of the bucket,
+// never below 0. A cost that adds up the cost of every bucket a pattern pops, see
+// PatternCost, lets the search remember the cheapest way to pop what is left from
+// the buckets it has not decided yet.
+type BucketCostFunc func(vendingMachine *[][]int, bucket int, numberPopped int) int
+
+// CostModel is the cost of a pop pattern and, when the cost adds up bucket by bucket,
+// the cost of a single bucket that gives the same total for FindCheapestPopPattern to
+// search faster.
+type CostModel struct {
+	Cost       CostFunc
+	BucketCost BucketCostFunc
+}
+
+// Cost models by name, as accepted on the command line
+var CostModels = map[string]*CostModel{
+	"buckets":  {Cost: BucketsTouchedCost, BucketCost: bucketTouchedCost},
+	"switches": {Cost: MotorSwitchesCost},
+	"depth":    {Cost: DepthDisturbedCost, BucketCost: depthDisturbedCost},
+}
+
+// Number of different buckets that need to be opened
+func BucketsTouchedCost(vendingMachine *[][]int, patterns *[]*PopPattern) int {
+	return PatternCost(vendingMachine, patterns, bucketTouchedCost)
+}
+
+// Every bucket that needs to be opened counts once
+func bucketTouchedCost(vendingMachine *[][]int, bucket int, numberPopped int) int {
+	return 1
+}
+
+// Number of times the motor has to move on to another bucket while popping in
+// pattern order
+func MotorSwitchesCost(vendingMachine *[][]int, patterns *[]*PopPattern) int {
+	switches := 0
+	for i := 1; i < len(*patterns); i++ {
+		if (*patterns)[i].Index != (*patterns)[i-1].Index {
+			switches++
+		}
+	}
+
+	return switches
+}
+
+// Number of products that slide forward in the buckets that were popped
+func DepthDisturbedCost(vendingMachine *[][]int, patterns *[]*PopPattern) int {
+	return PatternCost(vendingMachine, patterns, depthDisturbedCost)
+}
+
+func depthDisturbedCost(vendingMachine *[][]int, bucket int, numberPopped int) int {
+	return len((*vendingMachine)[bucket]) - numberPopped
+}
+
+// PatternCost adds up the cost of every bucket the patterns pop, pattern indexes
+// referring to the buckets of the vending machine.
+func PatternCost(vendingMachine *[][]int, patterns *[]*PopPattern, cost BucketCostFunc) int {
+	popped := map[int]int{}
+	for _, pattern := range *patterns {
+		popped[pattern.Index] += pattern.NumberPopped
+	}
+	buckets := make([]int, 0, len(popped))
+	for bucket, numberPopped := range popped {
+		if numberPopped > 0 {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Ints(buckets)
+
+	total := 0
+	for _, bucket := range buckets {
+		total += cost(vendingMachine, bucket, popped[bucket])
+	}
+
+	return total
+}
+
+// Cost of a state that has no pattern, above any budget
+const noCost = int(^uint(0) >> 1)
+
+// Cheapest cost of a search state. When it is not exact the state has no pattern
+// cheaper than the cost, which is the budget it was searched with.
+type optimalState struct {
+	cost  int
+	exact bool
+	// Number popped from the bucket of the state on the cheapest pattern
+	numberPopped int
+}
+
+type optimalSearch struct {
+	*noOrderSearch
+	vendingMachine *[][]int
+	cost           BucketCostFunc
+	states         map[string]*optimalState
+}
+
+// Cheapest cost of popping the remaining products from bucket index i onward, when it
+// is below the budget. Like solve, the state is the bucket index with the remaining
+// products, and the cheapest cost of each state is remembered. A branch is cut as
+// soon as its bucket costs as much as the cheapest pattern found so far, in which case
+// the state only remembers that it has nothing cheaper than the budget.
+func (s *optimalSearch) cheapest(i int, budget int) (int, bool) {
+	if s.left == 0 {
+		return 0, budget > 0
+	}
+	if i == len(s.buckets) || !s.feasible(i) {
+		return noCost, false
+	}
+
+	key := s.key(i)
+	state, ok := s.states[key]
+	if ok && (state.exact || state.cost >= budget) {
+		return state.cost, state.exact && state.cost < budget
+	}
+
+	values := s.buckets[i].Values
+
+	taken := 0
+	for taken < len(values) && s.remaining[values[taken]] > 0 {
+		s.remaining[values[taken]]--
+		s.left--
+		taken++
+	}
+
+	best, found, bestPopped := budget, false, 0
+	for numberPopped := taken; numberPopped >= 0; numberPopped-- {
+		bucketCost := 0
+		if numberPopped > 0 {
+			bucketCost = s.cost(s.vendingMachine, s.buckets[i].Index, numberPopped)
+		}
+		if bucketCost < best {
+			if rest, ok := s.cheapest(i+1, best-bucketCost); ok {
+				best, found, bestPopped = bucketCost+rest, true, numberPopped
+			}
+		}
+		if numberPopped > 0 {
+			s.remaining[values[numberPopped-1]]++
+			s.left++
+		}
+	}
+
+	if found {
+		s.states[key] = &optimalState{cost: best, exact: true, numberPopped: bestPopped}
+		return best, true
+	}
+	s.states[key] = &optimalState{cost: budget}
+
+	return budget, false
+}
+
+// Follows the cheapest choice of every state from the first bucket, the states on the
+// way are exact since the cheapest pattern goes through them.
+func (s *optimalSearch) cheapestPatterns() *[]*PopPattern {
+	for i := 0; i < len(s.buckets) && s.left > 0; i++ {
+		numberPopped := s.states[s.key(i)].numberPopped
+		for _, product := range s.buckets[i].Values[:numberPopped] {
+			s.remaining[product]--
+			s.left--
+		}
+		s.popped[i] = numberPopped
+	}
+
+	return s.patterns()
+}
+
+// FindOptimalPopPattern goes through every pop pattern of the vending machine and
+// returns the one with the lowest cost, the first one found wins a tie.
+// Order of products does not matter.
+func FindOptimalPopPattern(vendingMachine *[][]int, products *[]int, cost CostFunc) (*[]*PopPattern, error) {
+	possibleSlices := scanPossibleSlices(vendingMachine, products)
+
+	var best *[]*PopPattern
+	var bestCost int
+
+	search := newNoOrderSearch(possibleSlices, *products)
+	search.run(0, func() bool {
+		mapped := toMachinePatterns(possibleSlices, search.patterns())
+		patternCost := cost(vendingMachine, mapped)
+		if best == nil || patternCost < bestCost {
+			best = mapped
+			bestCost = patternCost
+		}
+		return true
+	})
+
+	if best == nil {
+		return nil, ImpossibleErr
+	}
+
+	return best, nil
+}
+
+// FindOptimalPopPatternByBucket is FindOptimalPopPattern for a cost that adds up bucket
+// by bucket. Branches that can't beat the cheapest pattern so far are cut and the
+// cheapest way to finish every state is remembered, like FindMemoizedPopPattern
+// remembers the states that failed, so not every pattern is gone through.
+// Order of products does not matter.
+func FindOptimalPopPatternByBucket(vendingMachine *[][]int, products *[]int, cost BucketCostFunc) (*[]*PopPattern, error) {
+	possibleSlices := scanPossibleSlices(vendingMachine, products)
+
+	search := &optimalSearch{
+		noOrderSearch:  newNoOrderSearch(possibleSlices, *products),
+		vendingMachine: vendingMachine,
+		cost:           cost,
+		states:         map[string]*optimalState{},
+	}
+	if _, ok := search.cheapest(0, noCost); !ok {
+		return nil, ImpossibleErr
+	}
+
+	return toMachinePatterns(possibleSlices, search.cheapestPatterns()), nil
+}
+
+// FindCheapestPopPattern finds the pop pattern with the lowest cost of the model, by
+// bucket when the model has a bucket cost.
+// Order of products does not matter.
+func FindCheapestPopPattern(vendingMachine *[][]int, products *[]int, model *CostModel) (*[]*PopPattern, error) {
+	if model.BucketCost != nil {
+		return FindOptimalPopPatternByBucket(vendingMachine, products, model.BucketCost)
+	}
+
+	return FindOptimalPopPattern(vendingMachine, products, model.Cost)
+}
+
+func FindAndPopOptimal(vendingMachine *[][]int, products *[]int, cost CostFunc) error {
+	patterns, err := FindOptimalPopPattern(vendingMachine, products, cost)
+	if err == ImpossibleErr {
+		return Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	PopByPattern(vendingMachine, patterns)
+
+	return nil
+}
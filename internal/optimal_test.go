@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCostModels(t *testing.T) {
+	vendingMachine := [][]int{
+		{1, 2, 3},
+		{4, 5},
+		{6},
+	}
+	patterns := []*PopPattern{
+		{
+			Index:        0,
+			NumberPopped: 1,
+		},
+		{
+			Index:        1,
+			NumberPopped: 2,
+		},
+		{
+			Index:        0,
+			NumberPopped: 1,
+		},
+	}
+
+	data := []struct {
+		scenario     string
+		model        string
+		expectedCost int
+	}{
+		{
+			scenario:     "Buckets touched",
+			model:        "buckets",
+			expectedCost: 2,
+		},
+		{
+			scenario:     "Motor switches",
+			model:        "switches",
+			expectedCost: 2,
+		},
+		{
+			scenario:     "Depth disturbed",
+			model:        "depth",
+			expectedCost: 1,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			model := CostModels[d.model]
+			if cost := model.Cost(&vendingMachine, &patterns); cost != d.expectedCost {
+				t.Fatalf("Invalid cost, expected %d got %d", d.expectedCost, cost)
+			}
+			if model.BucketCost == nil {
+				return
+			}
+			if cost := PatternCost(&vendingMachine, &patterns, model.BucketCost); cost != d.expectedCost {
+				t.Fatalf("Invalid bucket cost, expected %d got %d", d.expectedCost, cost)
+			}
+		})
+	}
+}
+
+func TestFindOptimalPopPattern(t *testing.T) {
+	data := []struct {
+		scenario        string
+		vendingMachine  [][]int
+		products        []int
+		model           string
+		expectedPattern *[]*PopPattern
+	}{
+		{
+			scenario: "Fewest buckets",
+			vendingMachine: [][]int{
+				{1, 2, 3, 5, 5},
+				{2, 5, 4, 3, 1},
+				{3, 5, 4, 1, 1},
+				{5, 1, 1, 1, 1},
+			},
+			products: []int{1, 2, 3, 4, 5},
+			model:    "buckets",
+			expectedPattern: &[]*PopPattern{
+				{
+					Index:        1,
+					NumberPopped: 5,
+				},
+			},
+		},
+		{
+			scenario: "Least depth disturbed",
+			vendingMachine: [][]int{
+				{1, 9, 9, 9},
+				{1},
+			},
+			products: []int{1},
+			model:    "depth",
+			expectedPattern: &[]*PopPattern{
+				{
+					Index:        1,
+					NumberPopped: 1,
+				},
+			},
+		},
+		{
+			scenario: "Fewest motor switches",
+			vendingMachine: [][]int{
+				{1, 9},
+				{2, 9},
+				{1, 2},
+			},
+			products: []int{1, 2},
+			model:    "switches",
+			expectedPattern: &[]*PopPattern{
+				{
+					Index:        2,
+					NumberPopped: 2,
+				},
+			},
+		},
+		{
+			scenario: "Impossible",
+			vendingMachine: [][]int{
+				{1, 9, 9, 9},
+				{1},
+			},
+			products:        []int{9},
+			model:           "buckets",
+			expectedPattern: nil,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			model := CostModels[d.model]
+			optimal, err := FindOptimalPopPattern(&d.vendingMachine, &d.products, model.Cost)
+			cheapest, cheapestErr := FindCheapestPopPattern(&d.vendingMachine, &d.products, model)
+			if d.expectedPattern == nil {
+				if err != ImpossibleErr || cheapestErr != ImpossibleErr {
+					t.Fatalf("Expected impossible, got %v and %v", err, cheapestErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cheapestErr != nil {
+				t.Fatal(cheapestErr)
+			}
+			if err := assertEqualPatterns(optimal, d.expectedPattern); err != nil {
+				t.Fatal(err)
+			}
+			if err := assertEqualPatterns(cheapest, d.expectedPattern); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFindOptimalPopPatternByBucket_SameAsOptimal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 1000; n++ {
+		vendingMachine := randomVendingMachine(random, 1+random.Intn(5), 3, 4)
+		products := randomProducts(random, 1+random.Intn(5), 4)
+
+		optimal, optimalErr := FindOptimalPopPattern(&vendingMachine, &products, DepthDisturbedCost)
+		pattern, err := FindOptimalPopPatternByBucket(&vendingMachine, &products, depthDisturbedCost)
+		if optimalErr == ImpossibleErr {
+			if err != ImpossibleErr {
+				t.Fatalf("Vending machine %+v products %+v: expected impossible got %v", vendingMachine, products, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Vending machine %+v products %+v: %v", vendingMachine, products, err)
+		}
+		cheapest := DepthDisturbedCost(&vendingMachine, optimal)
+		if cost := DepthDisturbedCost(&vendingMachine, pattern); cost != cheapest {
+			t.Fatalf("Vending machine %+v products %+v: expected cost %d got %d", vendingMachine, products, cheapest, cost)
+		}
+		if err := checkPopsProducts(&vendingMachine, &products, pattern); err != nil {
+			t.Fatalf("Vending machine %+v products %+v: pattern does not pop the products", vendingMachine, products)
+		}
+	}
+}
+
+// 24 buckets x 4 depth, every complete pattern is too many to go through
+func BenchmarkFindCheapestPopPattern(b *testing.B) {
+	vendingMachine := randomVendingMachine(rand.New(rand.NewSource(1)), 24, 4, 6)
+	products := randomProducts(rand.New(rand.NewSource(2)), 24, 6)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := FindCheapestPopPattern(&vendingMachine, &products, CostModels["buckets"]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
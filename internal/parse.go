@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Characters of the input shown on each side of the token by Caret
+const caretContext = 30
+
+// Largest quantity of a product, far more than a bucket holds, so that a typo can't
+// run out of memory
+const maxQuantity = 1 << 16
+
+// Encodes an empty bucket or order where nothing would be ambiguous, as a vending
+// machine with a single empty bucket is "-" while "" has no buckets at all
+const emptyEncoding = "-"
+
+// ParseError tells where the encoding of the buckets or of the order is invalid, it
+// matches InvalidArgument
+type ParseError struct {
+	Input string
+	// Index of the bucket, -1 for an order
+	Bucket int
+	// Index of the product within the bucket or the order, the first one a quantity
+	// stands for
+	Item int
+	// Byte offset of the token in the input
+	Offset int
+	Token  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Bucket < 0 {
+		return fmt.Sprintf("%s: item %d at offset %d %q", InvalidArgument, e.Item, e.Offset, e.Token)
+	}
+
+	return fmt.Sprintf("%s: bucket %d item %d at offset %d %q", InvalidArgument, e.Bucket, e.Item, e.Offset, e.Token)
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == InvalidArgument
+}
+
+// Caret shows the input around the token with a caret under it, long inputs are cut
+// short on both sides.
+func (e *ParseError) Caret() string {
+	start, prefix := e.Offset-caretContext, "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	end, suffix := e.Offset+len(e.Token)+caretContext, "..."
+	if end >= len(e.Input) {
+		end, suffix = len(e.Input), ""
+	}
+
+	underline := "^"
+	if len(e.Token) > 1 {
+		underline += strings.Repeat("~", len(e.Token)-1)
+	}
+
+	return fmt.Sprintf("%s%s%s\n%s%s\n",
+		prefix, e.Input[start:end], suffix,
+		strings.Repeat(" ", len(prefix)+e.Offset-start), underline)
+}
+
+// Parses the products of a bucket or of an order, str starting at offset in the
+// input. The bucket is -1 for an order. A product can be followed by its quantity,
+// as in 5x4 or 5*4 for 5,5,5,5. Nothing or - is no products at all.
+func parseProducts(input string, str string, bucket int, offset int) ([]int, error) {
+	products := []int{}
+	if len(str) == 0 || str == emptyEncoding {
+		return products, nil
+	}
+
+	for _, token := range strings.Split(str, ",") {
+		product, quantity, err := parseQuantity(token)
+		if err != nil {
+			return nil, &ParseError{
+				Input:  input,
+				Bucket: bucket,
+				Item:   len(products),
+				Offset: offset,
+				Token:  token,
+			}
+		}
+		for i := 0; i < quantity; i++ {
+			products = append(products, product)
+		}
+		offset += len(token) + 1
+	}
+
+	return products, nil
+}
+
+func parseQuantity(token string) (int, int, error) {
+	separator := strings.IndexAny(token, "x*")
+	if separator == -1 {
+		product, err := strconv.Atoi(token)
+		return product, 1, err
+	}
+
+	product, err := strconv.Atoi(token[:separator])
+	if err != nil {
+		return 0, 0, err
+	}
+	quantity, err := strconv.Atoi(token[separator+1:])
+	if err != nil {
+		return 0, 0, err
+	}
+	if quantity < 1 || quantity > maxQuantity {
+		return 0, 0, InvalidArgument
+	}
+
+	return product, quantity, nil
+}
+
+// EncodeInput encodes the products like ParseInput reads them. The compact form
+// writes products repeated next to each other with their quantity, as in 5x4,2, and
+// an empty order is encoded as nothing.
+func EncodeInput(products *[]int, compact bool) string {
+	return encodeProducts(*products, compact)
+}
+
+// EncodeVendingMachine encodes the buckets like CreateFromString reads them. The
+// compact form writes products repeated next to each other with their quantity, as
+// in 1x3,2;4. Empty buckets are encoded as nothing, as in 1;;4, except for a single
+// empty bucket which is -.
+func EncodeVendingMachine(vendingMachine *[][]int, compact bool) string {
+	if len(*vendingMachine) == 1 && len((*vendingMachine)[0]) == 0 {
+		return emptyEncoding
+	}
+
+	buckets := make([]string, 0, len(*vendingMachine))
+	for _, bucket := range *vendingMachine {
+		buckets = append(buckets, encodeProducts(bucket, compact))
+	}
+
+	return strings.Join(buckets, ";")
+}
+
+func encodeProducts(products []int, compact bool) string {
+	tokens := make([]string, 0, len(products))
+	for i := 0; i < len(products); {
+		// Longer runs are written as several tokens, as parsing refuses larger quantities
+		run := 1
+		for compact && run < maxQuantity && i+run < len(products) && products[i+run] == products[i] {
+			run++
+		}
+		if run > 1 {
+			tokens = append(tokens, fmt.Sprintf("%dx%d", products[i], run))
+		} else {
+			tokens = append(tokens, strconv.Itoa(products[i]))
+		}
+		i += run
+	}
+
+	return strings.Join(tokens, ",")
+}
@@ -0,0 +1,324 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	data := []struct {
+		scenario      string
+		parse         func(str string) error
+		str           string
+		expectedError ParseError
+		expectedCaret string
+	}{
+		{
+			scenario: "Product of a bucket",
+			parse: func(str string) error {
+				_, err := CreateFromString(str)
+				return err
+			},
+			str: "1,2;3,x4,5;6",
+			expectedError: ParseError{
+				Input:  "1,2;3,x4,5;6",
+				Bucket: 1,
+				Item:   1,
+				Offset: 6,
+				Token:  "x4",
+			},
+			expectedCaret: "1,2;3,x4,5;6\n      ^~\n",
+		},
+		{
+			scenario: "Empty product at the end",
+			parse: func(str string) error {
+				_, err := CreateFromString(str)
+				return err
+			},
+			str: "1;2,",
+			expectedError: ParseError{
+				Input:  "1;2,",
+				Bucket: 1,
+				Item:   1,
+				Offset: 4,
+				Token:  "",
+			},
+			expectedCaret: "1;2,\n    ^\n",
+		},
+		{
+			scenario: "Product of an order",
+			parse: func(str string) error {
+				_, err := ParseInput(str)
+				return err
+			},
+			str: "1,2,a",
+			expectedError: ParseError{
+				Input:  "1,2,a",
+				Bucket: -1,
+				Item:   2,
+				Offset: 4,
+				Token:  "a",
+			},
+			expectedCaret: "1,2,a\n    ^\n",
+		},
+		{
+			scenario: "Long input is cut short",
+			parse: func(str string) error {
+				_, err := ParseInput(str)
+				return err
+			},
+			str: "1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,b,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1",
+			expectedError: ParseError{
+				Input:  "1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,b,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1",
+				Bucket: -1,
+				Item:   20,
+				Offset: 40,
+				Token:  "b",
+			},
+			expectedCaret: "...1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,b,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1...\n" +
+				"                                 ^\n",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			err := d.parse(d.str)
+			if !errors.Is(err, InvalidArgument) {
+				t.Fatalf("expected %v got %v", InvalidArgument, err)
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a parse error got %v", err)
+			}
+			if *parseErr != d.expectedError {
+				t.Errorf("expected %+v got %+v", d.expectedError, *parseErr)
+			}
+			if caret := parseErr.Caret(); caret != d.expectedCaret {
+				t.Errorf("expected caret\n%s got\n%s", d.expectedCaret, caret)
+			}
+		})
+	}
+}
+
+func TestParseInput_Quantity(t *testing.T) {
+	data := []struct {
+		scenario         string
+		input            string
+		expectedProducts []int
+	}{
+		{scenario: "Quantity with x", input: "5x4,2", expectedProducts: []int{5, 5, 5, 5, 2}},
+		{scenario: "Quantity with *", input: "2,5*3", expectedProducts: []int{2, 5, 5, 5}},
+		{scenario: "Quantity of one", input: "7x1", expectedProducts: []int{7}},
+		{scenario: "Negative product", input: "-1x2", expectedProducts: []int{-1, -1}},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			products, err := ParseInput(d.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if areEqualInt(*products, d.expectedProducts) == false {
+				t.Errorf("expected %+v got %+v", d.expectedProducts, *products)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"5x", "x4", "5x0", "5x-1", "5xx2", "5x2*2", "5x100000"} {
+		if _, err := ParseInput(invalid); !errors.Is(err, InvalidArgument) {
+			t.Errorf("expected %s to be invalid got %v", invalid, err)
+		}
+	}
+}
+
+func TestCreateFromString_Quantity(t *testing.T) {
+	vendingMachine, err := CreateFromString("1x3,2;4*2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int{{1, 1, 1, 2}, {4, 4}}
+	if !reflect.DeepEqual(*vendingMachine, expected) {
+		t.Errorf("expected %+v got %+v", expected, *vendingMachine)
+	}
+
+	_, err = CreateFromString("1x3,2;4,1x0,5")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error got %v", err)
+	}
+	expectedErr := ParseError{Input: "1x3,2;4,1x0,5", Bucket: 1, Item: 1, Offset: 8, Token: "1x0"}
+	if *parseErr != expectedErr {
+		t.Errorf("expected %+v got %+v", expectedErr, *parseErr)
+	}
+}
+
+func TestEncodeVendingMachine(t *testing.T) {
+	data := []struct {
+		scenario        string
+		str             string
+		expectedPlain   string
+		expectedCompact string
+	}{
+		{scenario: "Example", str: "1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1", expectedPlain: "1,2,3,5,5;2,5,4,3,1;3,5,4,1,1;5,1,1,1,1", expectedCompact: "1,2,3,5x2;2,5,4,3,1;3,5,4,1x2;5,1x4"},
+		{scenario: "Quantities", str: "1x3,2;4*2", expectedPlain: "1,1,1,2;4,4", expectedCompact: "1x3,2;4x2"},
+		{scenario: "Repeated apart", str: "1,2,1", expectedPlain: "1,2,1", expectedCompact: "1,2,1"},
+		{scenario: "Negative products", str: "-1,-1", expectedPlain: "-1,-1", expectedCompact: "-1x2"},
+		{scenario: "Empty bucket", str: "1,2;;3", expectedPlain: "1,2;;3", expectedCompact: "1,2;;3"},
+		{scenario: "Empty bucket marked", str: "1,2;-;3", expectedPlain: "1,2;;3", expectedCompact: "1,2;;3"},
+		{scenario: "Empty last bucket", str: "1;", expectedPlain: "1;", expectedCompact: "1;"},
+		{scenario: "Empty buckets", str: ";", expectedPlain: ";", expectedCompact: ";"},
+		{scenario: "Single empty bucket", str: "-", expectedPlain: "-", expectedCompact: "-"},
+		{scenario: "No buckets", str: "", expectedPlain: "", expectedCompact: ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine, err := CreateFromString(d.str)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, encoded := range []string{EncodeVendingMachine(vendingMachine, false), EncodeVendingMachine(vendingMachine, true)} {
+				decoded, err := CreateFromString(encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(decoded, vendingMachine) {
+					t.Errorf("expected %s to decode to %+v got %+v", encoded, *vendingMachine, *decoded)
+				}
+			}
+			if plain := EncodeVendingMachine(vendingMachine, false); plain != d.expectedPlain {
+				t.Errorf("expected %s got %s", d.expectedPlain, plain)
+			}
+			if compact := EncodeVendingMachine(vendingMachine, true); compact != d.expectedCompact {
+				t.Errorf("expected %s got %s", d.expectedCompact, compact)
+			}
+		})
+	}
+}
+
+func TestEncodeInput(t *testing.T) {
+	products := []int{5, 5, 5, 5, 2}
+	if encoded := EncodeInput(&products, true); encoded != "5x4,2" {
+		t.Errorf("expected 5x4,2 got %s", encoded)
+	}
+	if encoded := EncodeInput(&products, false); encoded != "5,5,5,5,2" {
+		t.Errorf("expected 5,5,5,5,2 got %s", encoded)
+	}
+}
+
+func TestEncodeInput_LongRun(t *testing.T) {
+	products := make([]int, maxQuantity+2)
+	for i := range products {
+		products[i] = 5
+	}
+	products = append(products, 2)
+
+	encoded := EncodeInput(&products, true)
+	if expected := fmt.Sprintf("5x%d,5x2,2", maxQuantity); encoded != expected {
+		t.Fatalf("expected %s got %s", expected, encoded)
+	}
+	parsed, err := ParseInput(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if areEqualInt(*parsed, products) == false {
+		t.Fatalf("expected %d products got %d", len(products), len(*parsed))
+	}
+}
+
+func TestEncodeVendingMachine_Popped(t *testing.T) {
+	data := []struct {
+		scenario string
+		str      string
+		products []int
+		expected string
+	}{
+		{scenario: "Bucket emptied", str: "1,2;3;4", products: []int{3}, expected: "1,2;;4"},
+		{scenario: "Only bucket emptied", str: "1,1", products: []int{1, 1}, expected: "-"},
+		{scenario: "All buckets emptied", str: "1;2", products: []int{1, 2}, expected: ";"},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine, err := CreateFromString(d.str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := FindAndPopByOrder(vendingMachine, &d.products, FindBacktrackingPattern); err != nil {
+				t.Fatal(err)
+			}
+
+			encoded := EncodeVendingMachine(vendingMachine, true)
+			if encoded != d.expected {
+				t.Errorf("expected %s got %s", d.expected, encoded)
+			}
+			decoded, err := CreateFromString(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, vendingMachine) {
+				t.Errorf("expected %s to decode to %+v got %+v", encoded, *vendingMachine, *decoded)
+			}
+		})
+	}
+}
+
+func TestParseInput_Empty(t *testing.T) {
+	for _, input := range []string{"", "-"} {
+		products, err := ParseInput(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*products) != 0 {
+			t.Errorf("expected %q to be an empty order got %+v", input, *products)
+		}
+		if encoded := EncodeInput(products, true); encoded != "" {
+			t.Errorf("expected an empty encoding got %s", encoded)
+		}
+	}
+
+	for _, invalid := range []string{",", "1,,2", "-,1", "1;2"} {
+		if _, err := ParseInput(invalid); !errors.Is(err, InvalidArgument) {
+			t.Errorf("expected %s to be invalid got %v", invalid, err)
+		}
+	}
+}
+
+func TestFindCumulativePopPattern_EmptyOrder(t *testing.T) {
+	data := []struct {
+		scenario string
+		str      string
+	}{
+		{scenario: "Buckets", str: "1,2;3"},
+		{scenario: "Empty bucket", str: "1;;3"},
+		{scenario: "No buckets", str: ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine, err := CreateFromString(d.str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			products := []int{}
+			for _, fn := range []PatternFunc{FindFirstPattern, FindFirstNoOrderPattern, FindBacktrackingPattern} {
+				patterns, err := FindCumulativePopPattern(vendingMachine, &products, fn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := assertEqualPatterns(patterns, &[]*PopPattern{}); err != nil {
+					t.Error(err)
+				}
+			}
+			if err := FindAndPopByOrder(vendingMachine, &products, FindFirstPattern); err != nil {
+				t.Fatal(err)
+			}
+			if encoded := EncodeVendingMachine(vendingMachine, false); encoded != d.str {
+				t.Errorf("expected %s got %s", d.str, encoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+// Most products that can be popped from bucket index i onward, with the remaining
+// products. Every bucket prefix is still limited to what the order asks for.
+func (s *noOrderSearch) most(i int, memo map[string]int) int {
+	if s.left == 0 || i == len(s.buckets) {
+		return 0
+	}
+
+	key := s.key(i)
+	if most, ok := memo[key]; ok {
+		return most
+	}
+
+	values := s.buckets[i].Values
+
+	taken := 0
+	for taken < len(values) && s.remaining[values[taken]] > 0 {
+		s.remaining[values[taken]]--
+		s.left--
+		taken++
+	}
+
+	most := 0
+	for numberPopped := taken; numberPopped >= 0; numberPopped-- {
+		if popped := numberPopped + s.most(i+1, memo); popped > most {
+			most = popped
+		}
+		if numberPopped > 0 {
+			s.remaining[values[numberPopped-1]]++
+			s.left++
+		}
+	}
+
+	memo[key] = most
+
+	return most
+}
+
+// FindPartialPopPattern finds the pop pattern that vends as many products of the order
+// as possible, returning it with the vended products and the ones left out. When the
+// whole order is possible nothing is left out.
+// Order of products does not matter.
+func FindPartialPopPattern(vendingMachine *[][]int, products *[]int) (*[]*PopPattern, *[]int, *[]int) {
+	possibleSlices := scanPossibleSlices(vendingMachine, products)
+	search := newNoOrderSearch(possibleSlices, *products)
+	memo := map[string]int{}
+
+	// Follow the choices that keep the most products vended
+	for i := range possibleSlices {
+		most := search.most(i, memo)
+		values := possibleSlices[i].Values
+
+		taken := 0
+		for taken < len(values) && search.remaining[values[taken]] > 0 {
+			search.remaining[values[taken]]--
+			search.left--
+			taken++
+		}
+		numberPopped := taken
+		for ; numberPopped > 0; numberPopped-- {
+			if numberPopped+search.most(i+1, memo) == most {
+				break
+			}
+			search.remaining[values[numberPopped-1]]++
+			search.left++
+		}
+		search.popped[i] = numberPopped
+	}
+
+	patterns := toMachinePatterns(possibleSlices, search.patterns())
+
+	vended := []int{}
+	for _, pattern := range *patterns {
+		vended = append(vended, (*vendingMachine)[pattern.Index][:pattern.NumberPopped]...)
+	}
+
+	remainder := make([]int, len(*products))
+	copy(remainder, *products)
+	for _, product := range vended {
+		cutIntFromSlice(&remainder, getIndexFromArray(product, remainder))
+	}
+
+	return patterns, &vended, &remainder
+}
+
+// FindAndPopPartialByOrder pops the largest part of the order that can be vended and
+// returns the vended products with the ones that could not be.
+func FindAndPopPartialByOrder(vendingMachine *[][]int, products *[]int) (*[]int, *[]int) {
+	patterns, vended, remainder := FindPartialPopPattern(vendingMachine, products)
+
+	PopByPattern(vendingMachine, patterns)
+
+	return vended, remainder
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestFindAndPopPartialByOrder(t *testing.T) {
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		products               []int
+		expectedVended         []int
+		expectedRemainder      []int
+		expectedVendingMachine [][]int
+	}{
+		{
+			scenario: "Whole order",
+			vendingMachine: [][]int{
+				{1, 2, 3, 5, 5},
+				{2, 5, 4, 3, 1},
+			},
+			products:          []int{1, 2, 3, 4, 5},
+			expectedVended:    []int{1, 2, 5, 4, 3},
+			expectedRemainder: []int{},
+			expectedVendingMachine: [][]int{
+				{2, 3, 5, 5},
+				{1},
+			},
+		},
+		{
+			scenario: "Largest part",
+			vendingMachine: [][]int{
+				{1, 9},
+				{2, 2, 3},
+				{3, 9},
+			},
+			products:          []int{1, 2, 3, 3, 7},
+			expectedVended:    []int{1, 2, 3},
+			expectedRemainder: []int{3, 7},
+			expectedVendingMachine: [][]int{
+				{9},
+				{2, 3},
+				{9},
+			},
+		},
+		{
+			scenario: "Nothing",
+			vendingMachine: [][]int{
+				{1, 9},
+			},
+			products:          []int{7},
+			expectedVended:    []int{},
+			expectedRemainder: []int{7},
+			expectedVendingMachine: [][]int{
+				{1, 9},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vended, remainder := FindAndPopPartialByOrder(&d.vendingMachine, &d.products)
+			if areEqualInt(*vended, d.expectedVended) == false {
+				t.Fatalf("Expected vended %+v, got %+v", d.expectedVended, *vended)
+			}
+			if areEqualInt(*remainder, d.expectedRemainder) == false {
+				t.Fatalf("Expected remainder %+v, got %+v", d.expectedRemainder, *remainder)
+			}
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, d.vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, d.vendingMachine[i], bucket)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var CapacityExceededErr = errors.New("bucket capacity exceeded")
+
+// RestockError tells which bucket would overflow, it matches CapacityExceededErr
+type RestockError struct {
+	Bucket   int
+	Capacity int
+	// Number of products the bucket would hold after the restock
+	Size int
+}
+
+func (e *RestockError) Error() string {
+	return fmt.Sprintf("%s: bucket %d holds at most %d products, restock makes it %d",
+		CapacityExceededErr, e.Bucket, e.Capacity, e.Size)
+}
+
+func (e *RestockError) Is(target error) bool {
+	return target == CapacityExceededErr
+}
+
+type Restock struct {
+	Bucket   int
+	Products []int
+	// Replace the products of the bucket instead of adding them to the back
+	Replace bool
+}
+
+func (r *Restock) Print() {
+	fmt.Printf("[Restock]: Bucket %d Products %+v Replace %t\n", r.Bucket, r.Products, r.Replace)
+}
+
+// Capacity of the bucket, 0 or a missing capacity means there is no limit
+func capacityOf(capacities *[]int, bucket int) int {
+	if capacities == nil || bucket >= len(*capacities) {
+		return 0
+	}
+
+	return (*capacities)[bucket]
+}
+
+// RestockBuckets checks every restock against the bucket capacities before applying
+// any of them, so the vending machine is left as it was when one does not fit.
+func RestockBuckets(vendingMachine *[][]int, capacities *[]int, restocks *[]*Restock) error {
+	sizes := make([]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		sizes[i] = len(bucket)
+	}
+
+	for _, restock := range *restocks {
+		if restock.Bucket < 0 || restock.Bucket >= len(*vendingMachine) {
+			return InvalidArgument
+		}
+		if restock.Replace {
+			sizes[restock.Bucket] = 0
+		}
+		sizes[restock.Bucket] += len(restock.Products)
+
+		capacity := capacityOf(capacities, restock.Bucket)
+		if capacity > 0 && sizes[restock.Bucket] > capacity {
+			return &RestockError{
+				Bucket:   restock.Bucket,
+				Capacity: capacity,
+				Size:     sizes[restock.Bucket],
+			}
+		}
+	}
+
+	for _, restock := range *restocks {
+		bucket := (*vendingMachine)[restock.Bucket]
+		if restock.Replace {
+			bucket = nil
+		}
+		restocked := make([]int, 0, len(bucket)+len(restock.Products))
+		restocked = append(restocked, bucket...)
+		(*vendingMachine)[restock.Bucket] = append(restocked, restock.Products...)
+	}
+
+	return nil
+}
+
+// ParseRestockManifest reads restocks separated by ; where <bucket>+<products> adds
+// the products to the back of the bucket and <bucket>=<products> replaces them,
+// as in 0+1,2;3=4,4
+func ParseRestockManifest(str string) (*[]*Restock, error) {
+	restocks := []*Restock{}
+	if len(str) == 0 {
+		return &restocks, nil
+	}
+
+	for _, encoded := range strings.Split(str, ";") {
+		separator := strings.IndexAny(encoded, "+=")
+		if separator == -1 {
+			return nil, InvalidArgument
+		}
+		bucket, err := strconv.Atoi(encoded[:separator])
+		if err != nil {
+			return nil, InvalidArgument
+		}
+
+		products := []int{}
+		if separator+1 < len(encoded) {
+			parsed, err := ParseInput(encoded[separator+1:])
+			if err != nil {
+				return nil, err
+			}
+			products = *parsed
+		}
+
+		restocks = append(restocks, &Restock{
+			Bucket:   bucket,
+			Products: products,
+			Replace:  encoded[separator] == '=',
+		})
+	}
+
+	return &restocks, nil
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRestockManifest(t *testing.T) {
+	restocks, err := ParseRestockManifest("0+1,2;3=4,4;1=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Restock{
+		{Bucket: 0, Products: []int{1, 2}},
+		{Bucket: 3, Products: []int{4, 4}, Replace: true},
+		{Bucket: 1, Products: []int{}, Replace: true},
+	}
+	if len(*restocks) != len(expected) {
+		t.Fatalf("Expected %d restocks, got %d", len(expected), len(*restocks))
+	}
+	for i, restock := range expected {
+		got := (*restocks)[i]
+		if got.Bucket != restock.Bucket || got.Replace != restock.Replace || areEqualInt(got.Products, restock.Products) == false {
+			t.Fatalf("Expected restock %+v, got %+v", *restock, *got)
+		}
+	}
+
+	for _, invalid := range []string{"0", "a+1", "0+1,a"} {
+		if _, err := ParseRestockManifest(invalid); !errors.Is(err, InvalidArgument) {
+			t.Fatalf("Expected %s to be invalid, got %v", invalid, err)
+		}
+	}
+}
+
+func TestRestockBuckets(t *testing.T) {
+	data := []struct {
+		scenario               string
+		capacities             []int
+		restocks               []*Restock
+		expectedErr            error
+		expectedVendingMachine [][]int
+	}{
+		{
+			scenario:   "Append and replace",
+			capacities: []int{3, 2},
+			restocks: []*Restock{
+				{Bucket: 0, Products: []int{5}},
+				{Bucket: 1, Products: []int{7, 7}, Replace: true},
+			},
+			expectedVendingMachine: [][]int{
+				{1, 2, 5},
+				{7, 7},
+			},
+		},
+		{
+			scenario: "No capacity",
+			restocks: []*Restock{
+				{Bucket: 0, Products: []int{5, 5, 5}},
+			},
+			expectedVendingMachine: [][]int{
+				{1, 2, 5, 5, 5},
+				{3},
+			},
+		},
+		{
+			scenario:   "Over capacity",
+			capacities: []int{4, 2},
+			restocks: []*Restock{
+				{Bucket: 1, Products: []int{3}},
+				{Bucket: 0, Products: []int{5}},
+				{Bucket: 0, Products: []int{5, 5}},
+			},
+			expectedErr: &RestockError{Bucket: 0, Capacity: 4, Size: 5},
+			expectedVendingMachine: [][]int{
+				{1, 2},
+				{3},
+			},
+		},
+		{
+			scenario: "No such bucket",
+			restocks: []*Restock{
+				{Bucket: 2, Products: []int{5}},
+			},
+			expectedErr: InvalidArgument,
+			expectedVendingMachine: [][]int{
+				{1, 2},
+				{3},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine := [][]int{{1, 2}, {3}}
+			err := RestockBuckets(&vendingMachine, &d.capacities, &d.restocks)
+
+			var restockErr *RestockError
+			if expectedRestockErr, ok := d.expectedErr.(*RestockError); ok {
+				if !errors.Is(err, CapacityExceededErr) || !errors.As(err, &restockErr) || *restockErr != *expectedRestockErr {
+					t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+				}
+			} else if err != d.expectedErr {
+				t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+			}
+
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, vendingMachine[i], bucket)
+				}
+			}
+		})
+	}
+}
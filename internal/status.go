@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var OutOfServiceErr = errors.New("bucket is out of service")
+
+// BucketStatus tells whether the bucket can vend, only active buckets can
+type BucketStatus int
+
+const (
+	Active BucketStatus = iota
+	Jammed
+	Maintenance
+)
+
+var bucketStatusNames = map[BucketStatus]string{
+	Active:      "active",
+	Jammed:      "jammed",
+	Maintenance: "maintenance",
+}
+
+func (s BucketStatus) String() string {
+	if name, ok := bucketStatusNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("BucketStatus(%d)", int(s))
+}
+
+func (s BucketStatus) MarshalText() ([]byte, error) {
+	name, ok := bucketStatusNames[s]
+	if !ok {
+		return nil, InvalidArgument
+	}
+
+	return []byte(name), nil
+}
+
+func (s *BucketStatus) UnmarshalText(text []byte) error {
+	status, err := ParseBucketStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+
+	return nil
+}
+
+func ParseBucketStatus(str string) (BucketStatus, error) {
+	for status, name := range bucketStatusNames {
+		if name == str {
+			return status, nil
+		}
+	}
+
+	return Active, InvalidArgument
+}
+
+// ParseStatuses reads the status of each bucket separated by , as in active,jammed,maintenance
+// where an empty status is active.
+func ParseStatuses(str string) (*[]BucketStatus, error) {
+	statuses := []BucketStatus{}
+	if len(str) == 0 {
+		return &statuses, nil
+	}
+
+	for _, name := range strings.Split(str, ",") {
+		status := Active
+		if len(name) > 0 {
+			parsed, err := ParseBucketStatus(name)
+			if err != nil {
+				return nil, err
+			}
+			status = parsed
+		}
+		statuses = append(statuses, status)
+	}
+
+	return &statuses, nil
+}
+
+// Status of the bucket, a missing status means it is active
+func statusOf(statuses *[]BucketStatus, bucket int) BucketStatus {
+	if statuses == nil || bucket >= len(*statuses) {
+		return Active
+	}
+
+	return (*statuses)[bucket]
+}
+
+// SetBucketStatus changes the status of the bucket, adding active statuses for the
+// buckets before it when they are missing.
+func SetBucketStatus(vendingMachine *[][]int, statuses *[]BucketStatus, bucket int, status BucketStatus) error {
+	if bucket < 0 || bucket >= len(*vendingMachine) {
+		return InvalidArgument
+	}
+	if _, ok := bucketStatusNames[status]; !ok {
+		return InvalidArgument
+	}
+	for len(*statuses) <= bucket {
+		*statuses = append(*statuses, Active)
+	}
+	(*statuses)[bucket] = status
+
+	return nil
+}
+
+// CheckInService returns OutOfServiceErr when one of the patterns pops a bucket that
+// is not active.
+func CheckInService(statuses *[]BucketStatus, patterns *[]*PopPattern) error {
+	for _, pattern := range *patterns {
+		if pattern.NumberPopped > 0 && statusOf(statuses, pattern.Index) != Active {
+			return OutOfServiceErr
+		}
+	}
+
+	return nil
+}
+
+// View of the vending machine where every bucket shows at most its first limits[i]
+// products, so that the pattern functions can't reach the ones behind.
+func limitedView(vendingMachine *[][]int, limits []int) *[][]int {
+	view := make([][]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		if limits[i] < len(bucket) {
+			bucket = bucket[:limits[i]]
+		}
+		view[i] = bucket
+	}
+
+	return &view
+}
+
+// Runs fn on the limited view and pops from the vending machine what fn popped from
+// the view. Fn must only pop from the front of the buckets.
+func withLimits(vendingMachine *[][]int, limits []int, fn func(view *[][]int) error) error {
+	view := limitedView(vendingMachine, limits)
+	shown := make([]int, len(*view))
+	for i, bucket := range *view {
+		shown[i] = len(bucket)
+	}
+
+	if err := fn(view); err != nil {
+		return err
+	}
+
+	for i, bucket := range *view {
+		(*vendingMachine)[i] = (*vendingMachine)[i][shown[i]-len(bucket):]
+	}
+
+	return nil
+}
+
+func serviceLimits(vendingMachine *[][]int, statuses *[]BucketStatus) []int {
+	limits := make([]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		if statusOf(statuses, i) == Active {
+			limits[i] = len(bucket)
+		}
+	}
+
+	return limits
+}
+
+// InService is the vending machine as the pattern functions see it, with the
+// buckets that are out of service empty.
+func InService(vendingMachine *[][]int, statuses *[]BucketStatus) *[][]int {
+	return limitedView(vendingMachine, serviceLimits(vendingMachine, statuses))
+}
+
+// WithStatus runs fn on the buckets in service, as in
+// WithStatus(vendingMachine, statuses, func(view *[][]int) error { return FindAndPopByOrder(view, products, fn) })
+// and pops from the vending machine what fn popped.
+func WithStatus(vendingMachine *[][]int, statuses *[]BucketStatus, fn func(view *[][]int) error) error {
+	return withLimits(vendingMachine, serviceLimits(vendingMachine, statuses), fn)
+}
+
+// FindCumulativePopPatternInService is FindCumulativePopPattern skipping the buckets
+// that are out of service.
+func FindCumulativePopPatternInService(vendingMachine *[][]int, statuses *[]BucketStatus, products *[]int, fn PatternFunc) (*[]*PopPattern, error) {
+	return FindCumulativePopPattern(InService(vendingMachine, statuses), products, fn)
+}
+
+func FindAndPopByOrderInService(vendingMachine *[][]int, statuses *[]BucketStatus, products *[]int, fn PatternFunc) error {
+	return WithStatus(vendingMachine, statuses, func(view *[][]int) error {
+		return FindAndPopByOrder(view, products, fn)
+	})
+}
+
+// PrintPrettyWithStatus is PrintPretty marking the buckets that are out of service
+func PrintPrettyWithStatus(vendingMachine *[][]int, statuses *[]BucketStatus) {
+	fmt.Println("Vending machine")
+	for i, bucket := range *vendingMachine {
+		if status := statusOf(statuses, i); status != Active {
+			fmt.Printf("\t%+v %s\n", bucket, status)
+			continue
+		}
+		fmt.Printf("\t%+v\n", bucket)
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFindAndPopByOrderInService(t *testing.T) {
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		statuses               []BucketStatus
+		products               []int
+		fn                     PatternFunc
+		expectedVendingMachine [][]int
+		expectedErr            error
+	}{
+		{
+			scenario:       "Skips the jammed bucket",
+			vendingMachine: [][]int{{1, 2}, {2, 1}},
+			statuses:       []BucketStatus{Jammed},
+			products:       []int{2},
+			fn:             FindBacktrackingPattern,
+			expectedVendingMachine: [][]int{
+				{1, 2},
+				{1},
+			},
+		},
+		{
+			scenario:       "Missing statuses are active",
+			vendingMachine: [][]int{{1}, {2}, {3}},
+			statuses:       []BucketStatus{Active, Maintenance},
+			products:       []int{3, 1},
+			fn:             FindFirstNoOrderPattern,
+			expectedVendingMachine: [][]int{
+				{},
+				{2},
+				{},
+			},
+		},
+		{
+			scenario:       "Products only in buckets under maintenance",
+			vendingMachine: [][]int{{1}, {2}},
+			statuses:       []BucketStatus{Active, Maintenance},
+			products:       []int{1, 2},
+			fn:             FindBacktrackingPattern,
+			expectedVendingMachine: [][]int{
+				{1},
+				{2},
+			},
+			expectedErr: ImpossibleErr,
+		},
+		{
+			scenario:       "Strict order around an out of service bucket",
+			vendingMachine: [][]int{{5}, {5, 1}, {2}},
+			statuses:       []BucketStatus{Jammed},
+			products:       []int{5, 1, 2},
+			fn:             FindFirstPattern,
+			expectedVendingMachine: [][]int{
+				{5},
+				{},
+				{},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			err := FindAndPopByOrderInService(&d.vendingMachine, &d.statuses, &d.products, d.fn)
+			if d.expectedErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if d.expectedErr != nil && !errors.Is(err, d.expectedErr) {
+				t.Fatalf("expected error %v got %v", d.expectedErr, err)
+			}
+			if !reflect.DeepEqual(d.vendingMachine, d.expectedVendingMachine) {
+				t.Errorf("expected %+v got %+v", d.expectedVendingMachine, d.vendingMachine)
+			}
+		})
+	}
+}
+
+func TestParseStatuses(t *testing.T) {
+	statuses, err := ParseStatuses("active,,jammed,maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BucketStatus{Active, Active, Jammed, Maintenance}
+	if !reflect.DeepEqual(*statuses, expected) {
+		t.Errorf("expected %+v got %+v", expected, *statuses)
+	}
+
+	if _, err := ParseStatuses("active,broken"); err != InvalidArgument {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
+
+func TestSetBucketStatus(t *testing.T) {
+	vendingMachine := [][]int{{1}, {2}, {3}}
+	statuses := []BucketStatus{}
+
+	if err := SetBucketStatus(&vendingMachine, &statuses, 2, Jammed); err != nil {
+		t.Fatal(err)
+	}
+	expected := []BucketStatus{Active, Active, Jammed}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected %+v got %+v", expected, statuses)
+	}
+
+	if err := SetBucketStatus(&vendingMachine, &statuses, 3, Jammed); err != InvalidArgument {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+	if err := SetBucketStatus(&vendingMachine, &statuses, 0, BucketStatus(7)); err != InvalidArgument {
+		t.Errorf("expected %v got %v", InvalidArgument, err)
+	}
+}
+
+func TestCheckInService(t *testing.T) {
+	statuses := []BucketStatus{Active, Jammed}
+
+	if err := CheckInService(&statuses, &[]*PopPattern{{Index: 0, NumberPopped: 1}, {Index: 2, NumberPopped: 1}}); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+	if err := CheckInService(&statuses, &[]*PopPattern{{Index: 1, NumberPopped: 1}}); err != OutOfServiceErr {
+		t.Errorf("expected %v got %v", OutOfServiceErr, err)
+	}
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VendStep is a single pop from the front of a bucket, one motor action
+type VendStep struct {
+	Bucket  int
+	Product int
+	// Number of products in the bucket before the pop
+	Depth int
+}
+
+var StepMismatchErr = errors.New("vend step does not match the bucket")
+
+func (vs *VendStep) Print() {
+	fmt.Printf("[VendStep]: Bucket %d Product %d Depth %d\n", vs.Bucket, vs.Product, vs.Depth)
+}
+
+// PatternsToSteps spells out the pop patterns one pop at a time, in the order of the
+// patterns, so that a bucket popped by several patterns is followed product by product.
+func PatternsToSteps(vendingMachine *[][]int, patterns *[]*PopPattern) (*[]*VendStep, error) {
+	steps := []*VendStep{}
+	offsets := make([]int, len(*vendingMachine))
+
+	for _, pattern := range *patterns {
+		if pattern.Index < 0 || pattern.Index >= len(*vendingMachine) {
+			return nil, InvalidArgument
+		}
+		bucket := (*vendingMachine)[pattern.Index]
+
+		for i := 0; i < pattern.NumberPopped; i++ {
+			offset := offsets[pattern.Index]
+			if offset >= len(bucket) {
+				return nil, InvalidArgument
+			}
+			steps = append(steps, &VendStep{
+				Bucket:  pattern.Index,
+				Product: bucket[offset],
+				Depth:   len(bucket) - offset,
+			})
+			offsets[pattern.Index]++
+		}
+	}
+
+	return &steps, nil
+}
+
+// FindVendSteps finds the pop pattern like FindCumulativePopPattern does and returns
+// it as vend steps.
+func FindVendSteps(vendingMachine *[][]int, products *[]int, fn PatternFunc) (*[]*VendStep, error) {
+	patterns, err := FindCumulativePopPattern(vendingMachine, products, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return PatternsToSteps(vendingMachine, patterns)
+}
+
+// PopByStep pops one product, after checking that the bucket is as the step expects.
+func PopByStep(vendingMachine *[][]int, step *VendStep) error {
+	if step.Bucket < 0 || step.Bucket >= len(*vendingMachine) {
+		return InvalidArgument
+	}
+	bucket := (*vendingMachine)[step.Bucket]
+	if len(bucket) == 0 || len(bucket) != step.Depth || bucket[0] != step.Product {
+		return StepMismatchErr
+	}
+
+	(*vendingMachine)[step.Bucket] = bucket[1:]
+
+	return nil
+}
+
+// PopBySteps pops the steps in order, just like PopByPattern would pop their patterns.
+// It stops at the first step that doesn't match, leaving the previous ones popped.
+func PopBySteps(vendingMachine *[][]int, steps *[]*VendStep) error {
+	for _, step := range *steps {
+		if err := PopByStep(vendingMachine, step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
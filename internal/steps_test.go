@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestFindVendSteps(t *testing.T) {
+	vendingMachine := [][]int{
+		{2},
+		{5, 1, 2},
+	}
+	products := []int{5, 2, 1}
+	expectedSteps := []*VendStep{
+		{Bucket: 1, Product: 5, Depth: 3},
+		{Bucket: 0, Product: 2, Depth: 1},
+		{Bucket: 1, Product: 1, Depth: 2},
+	}
+
+	steps, err := FindVendSteps(&vendingMachine, &products, FindFirstPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*steps) != len(expectedSteps) {
+		t.Fatalf("Expected %d steps, got %d", len(expectedSteps), len(*steps))
+	}
+	for i, step := range expectedSteps {
+		if *(*steps)[i] != *step {
+			t.Fatalf("Expected step %+v, got %+v", *step, *(*steps)[i])
+		}
+	}
+
+	if err := PopBySteps(&vendingMachine, steps); err != nil {
+		t.Fatal(err)
+	}
+	if len(vendingMachine[0]) != 0 || areEqualInt(vendingMachine[1], []int{2}) == false {
+		t.Fatalf("Invalid vending machine %+v", vendingMachine)
+	}
+}
+
+func TestPopBySteps_Mismatch(t *testing.T) {
+	data := []struct {
+		scenario               string
+		steps                  []*VendStep
+		expectedErr            error
+		expectedVendingMachine [][]int
+	}{
+		{
+			scenario: "Other product in front",
+			steps: []*VendStep{
+				{Bucket: 0, Product: 1, Depth: 2},
+				{Bucket: 0, Product: 1, Depth: 1},
+			},
+			expectedErr: StepMismatchErr,
+			expectedVendingMachine: [][]int{
+				{2},
+			},
+		},
+		{
+			scenario: "Bucket was restocked",
+			steps: []*VendStep{
+				{Bucket: 0, Product: 1, Depth: 3},
+			},
+			expectedErr: StepMismatchErr,
+			expectedVendingMachine: [][]int{
+				{1, 2},
+			},
+		},
+		{
+			scenario: "No such bucket",
+			steps: []*VendStep{
+				{Bucket: 1, Product: 1, Depth: 1},
+			},
+			expectedErr: InvalidArgument,
+			expectedVendingMachine: [][]int{
+				{1, 2},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vendingMachine := [][]int{{1, 2}}
+			if err := PopBySteps(&vendingMachine, &d.steps); err != d.expectedErr {
+				t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+			}
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, vendingMachine[i], bucket)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Equivalences are groups of interchangeable products, each group listed from the
+// most to the least preferred product.
+type Equivalences struct {
+	groups map[int][]int
+}
+
+type Substitution struct {
+	Requested int
+	Used      int
+}
+
+func (s *Substitution) Print() {
+	fmt.Printf("[Substitution]: Requested %d Used %d\n", s.Requested, s.Used)
+}
+
+// Groups are encoded like the vending machine buckets, 5,7,9;3,4 makes 5, 7 and 9
+// interchangeable and 3 and 4. A product can only belong to one group.
+func NewEquivalences(groups *[][]int) (*Equivalences, error) {
+	equivalences := &Equivalences{groups: map[int][]int{}}
+
+	for _, group := range *groups {
+		for _, product := range group {
+			if _, ok := equivalences.groups[product]; ok {
+				return nil, InvalidArgument
+			}
+			equivalences.groups[product] = group
+		}
+	}
+
+	return equivalences, nil
+}
+
+// Product that stands for the whole group
+func (e *Equivalences) canonical(product int) int {
+	if group, ok := e.groups[product]; ok {
+		return group[0]
+	}
+
+	return product
+}
+
+// Preference of the product within its group, 0 being the most preferred
+func (e *Equivalences) rank(product int) int {
+	return getIndexFromArray(product, e.groups[product])
+}
+
+func (e *Equivalences) canonicalMachine(vendingMachine *[][]int) *[][]int {
+	machine := make([][]int, len(*vendingMachine))
+	for i, bucket := range *vendingMachine {
+		machine[i] = *e.canonicalProducts(&bucket)
+	}
+
+	return &machine
+}
+
+func (e *Equivalences) canonicalProducts(products *[]int) *[]int {
+	canonical := make([]int, len(*products))
+	for i, product := range *products {
+		canonical[i] = e.canonical(product)
+	}
+
+	return &canonical
+}
+
+// Pairs the requested products with the popped ones, exact matches first, and the
+// rest in order of preference.
+func (e *Equivalences) substitutions(products *[]int, popped []int) []*Substitution {
+	unmatched := make([]int, len(popped))
+	copy(unmatched, popped)
+
+	var missing []int
+	for _, product := range *products {
+		index := getIndexFromArray(product, unmatched)
+		if index == -1 {
+			missing = append(missing, product)
+			continue
+		}
+		cutIntFromSlice(&unmatched, index)
+	}
+
+	sort.SliceStable(unmatched, func(i, j int) bool {
+		return e.rank(unmatched[i]) < e.rank(unmatched[j])
+	})
+
+	substitutions := []*Substitution{}
+	for _, product := range missing {
+		for i, used := range unmatched {
+			if e.canonical(used) == e.canonical(product) {
+				substitutions = append(substitutions, &Substitution{Requested: product, Used: used})
+				cutIntFromSlice(&unmatched, i)
+				break
+			}
+		}
+	}
+
+	return substitutions
+}
+
+// FindSubstitutePopPattern finds a pop pattern where products can be replaced by the
+// ones equivalent to them. The exact order is always preferred, otherwise the pattern
+// with the fewest substitutions wins, and then the one using the most preferred
+// substitutes. Returns the substitutions that were made.
+// Order of products does not matter.
+func FindSubstitutePopPattern(vendingMachine *[][]int, products *[]int, equivalences *Equivalences) (*[]*PopPattern, *[]*Substitution, error) {
+	if patterns, err := FindCumulativePopPattern(vendingMachine, products, FindBacktrackingPattern); err == nil {
+		return patterns, &[]*Substitution{}, nil
+	}
+
+	var best *[]*PopPattern
+	var bestSubstitutions []*Substitution
+	bestRanks := 0
+
+	canonicalMachine := equivalences.canonicalMachine(vendingMachine)
+	canonicalProducts := equivalences.canonicalProducts(products)
+
+	EnumeratePopPatterns(canonicalMachine, canonicalProducts, 0, func(patterns *[]*PopPattern) bool {
+		var popped []int
+		for _, pattern := range *patterns {
+			popped = append(popped, (*vendingMachine)[pattern.Index][:pattern.NumberPopped]...)
+		}
+
+		substitutions := equivalences.substitutions(products, popped)
+		ranks := 0
+		for _, substitution := range substitutions {
+			ranks += equivalences.rank(substitution.Used)
+		}
+
+		if best == nil || len(substitutions) < len(bestSubstitutions) ||
+			(len(substitutions) == len(bestSubstitutions) && ranks < bestRanks) {
+			best = patterns
+			bestSubstitutions = substitutions
+			bestRanks = ranks
+		}
+		return true
+	})
+
+	if best == nil {
+		return nil, nil, ImpossibleErr
+	}
+
+	return best, &bestSubstitutions, nil
+}
+
+func FindAndPopWithSubstitutes(vendingMachine *[][]int, products *[]int, equivalences *Equivalences) (*[]*Substitution, error) {
+	patterns, substitutions, err := FindSubstitutePopPattern(vendingMachine, products, equivalences)
+	if err == ImpossibleErr {
+		return nil, Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	PopByPattern(vendingMachine, patterns)
+
+	return substitutions, nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestNewEquivalences(t *testing.T) {
+	if _, err := NewEquivalences(&[][]int{{5, 7}, {3, 5}}); err != InvalidArgument {
+		t.Fatalf("Expected invalid argument for a product in two groups, got %v", err)
+	}
+}
+
+func TestFindAndPopWithSubstitutes(t *testing.T) {
+	data := []struct {
+		scenario               string
+		vendingMachine         [][]int
+		products               []int
+		groups                 [][]int
+		expectedSubstitutions  []*Substitution
+		expectedVendingMachine [][]int
+	}{
+		{
+			scenario: "Exact match preferred",
+			vendingMachine: [][]int{
+				{7, 1},
+				{5, 1},
+			},
+			products:              []int{5},
+			groups:                [][]int{{5, 7}},
+			expectedSubstitutions: []*Substitution{},
+			expectedVendingMachine: [][]int{
+				{7, 1},
+				{1},
+			},
+		},
+		{
+			scenario: "Fewest substitutions",
+			vendingMachine: [][]int{
+				{7, 7},
+				{5, 1},
+			},
+			products: []int{5, 5, 1},
+			groups:   [][]int{{5, 7}},
+			expectedSubstitutions: []*Substitution{
+				{Requested: 5, Used: 7},
+			},
+			expectedVendingMachine: [][]int{
+				{7},
+				{},
+			},
+		},
+		{
+			scenario: "Preferred substitute",
+			vendingMachine: [][]int{
+				{9, 1},
+				{7, 1},
+			},
+			products: []int{5},
+			groups:   [][]int{{5, 7, 9}},
+			expectedSubstitutions: []*Substitution{
+				{Requested: 5, Used: 7},
+			},
+			expectedVendingMachine: [][]int{
+				{9, 1},
+				{1},
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			equivalences, err := NewEquivalences(&d.groups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			substitutions, err := FindAndPopWithSubstitutes(&d.vendingMachine, &d.products, equivalences)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*substitutions) != len(d.expectedSubstitutions) {
+				t.Fatalf("Expected %d substitutions, got %d", len(d.expectedSubstitutions), len(*substitutions))
+			}
+			for i, substitution := range d.expectedSubstitutions {
+				if *(*substitutions)[i] != *substitution {
+					t.Fatalf("Expected substitution %+v, got %+v", *substitution, *(*substitutions)[i])
+				}
+			}
+			for i, bucket := range d.expectedVendingMachine {
+				if areEqualInt(bucket, d.vendingMachine[i]) == false {
+					t.Fatalf("Invalid bucket %d: got %+v expected %+v", i, d.vendingMachine[i], bucket)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"errors"
+)
+
+var TransactionClosedErr = errors.New("transaction is closed")
+var ReservedBucketErr = errors.New("bucket is reserved by a transaction")
+var TransactionConflictErr = errors.New("bucket changed since the transaction popped it")
+
+// Products popped by one pattern, enough to put them back, and the products the
+// bucket kept, so that they are only put back where nothing else changed
+type undoEntry struct {
+	Index  int
+	Popped []int
+	Kept   []int
+}
+
+// Products a pattern is expected to pop, with the number of products the bucket has
+// before, like the vend steps of the pattern
+type reservedPop struct {
+	Products []int
+	Depth    int
+}
+
+// Transaction pops the patterns one at a time, so that a vend failing halfway
+// through can be reverted to the exact bucket contents from before.
+type Transaction struct {
+	vendingMachine *[][]int
+	patterns       []*PopPattern
+	// One entry for every pattern, in order
+	reserved []*reservedPop
+	// One entry for every applied pattern, in order
+	undoLog []*undoEntry
+	closed  bool
+	// Records the pops and what is put back, when the transaction began on a log
+	log *EventLog
+}
+
+// Begin reserves the pop patterns for the transaction, after checking that the buckets
+// have enough products for them. Nothing is popped until Step or Commit, which check
+// that the buckets still have the products they had when the transaction began.
+func Begin(vendingMachine *[][]int, patterns *[]*PopPattern) (*Transaction, error) {
+	if err := CheckPatterns(vendingMachine, patterns); err != nil {
+		return nil, err
+	}
+
+	popped := make([]int, len(*vendingMachine))
+	reserved := make([]*reservedPop, 0, len(*patterns))
+	for _, pattern := range *patterns {
+		bucket := (*vendingMachine)[pattern.Index]
+		offset := popped[pattern.Index]
+		popped[pattern.Index] += pattern.NumberPopped
+		reserved = append(reserved, &reservedPop{
+			Products: append([]int{}, bucket[offset:popped[pattern.Index]]...),
+			Depth:    len(bucket) - offset,
+		})
+	}
+
+	return &Transaction{
+		vendingMachine: vendingMachine,
+		patterns:       copyPatterns(*patterns),
+		reserved:       reserved,
+	}, nil
+}
+
+// BeginOrder finds the pop pattern like FindCumulativePopPattern does and begins it.
+func BeginOrder(vendingMachine *[][]int, products *[]int, fn PatternFunc) (*Transaction, error) {
+	patterns, err := FindCumulativePopPattern(vendingMachine, products, fn)
+	if err == ImpossibleErr {
+		return nil, Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Begin(vendingMachine, patterns)
+}
+
+// Number of patterns that were popped
+func (t *Transaction) Applied() int {
+	return len(t.undoLog)
+}
+
+// Number of patterns left to pop
+func (t *Transaction) Pending() int {
+	return len(t.patterns) - len(t.undoLog)
+}
+
+// Closed tells whether the transaction was committed or rolled back.
+func (t *Transaction) Closed() bool {
+	return t.closed
+}
+
+// Buckets reserved by the transaction until it is closed, the ones it pops or may
+// put products back to. Nothing else should pop them in the meantime.
+func (t *Transaction) Buckets() map[int]bool {
+	buckets := map[int]bool{}
+	if t.closed {
+		return buckets
+	}
+	for _, pattern := range t.patterns {
+		if pattern.NumberPopped > 0 {
+			buckets[pattern.Index] = true
+		}
+	}
+
+	return buckets
+}
+
+// Step pops the next pattern, returning false when there was none left. Like
+// PopByStep it returns StepMismatchErr, popping nothing, when the bucket is not as
+// the pattern expects.
+func (t *Transaction) Step() (bool, error) {
+	if t.closed {
+		return false, TransactionClosedErr
+	}
+	if t.Pending() == 0 {
+		return false, nil
+	}
+
+	pattern := t.patterns[len(t.undoLog)]
+	reserved := t.reserved[len(t.undoLog)]
+	bucket := (*t.vendingMachine)[pattern.Index]
+	if len(bucket) != reserved.Depth || !isPrefix(reserved.Products, bucket) {
+		return false, StepMismatchErr
+	}
+
+	t.undoLog = append(t.undoLog, &undoEntry{
+		Index:  pattern.Index,
+		Popped: append([]int{}, bucket[:pattern.NumberPopped]...),
+		Kept:   append([]int{}, bucket[pattern.NumberPopped:]...),
+	})
+	(*t.vendingMachine)[pattern.Index] = bucket[pattern.NumberPopped:]
+	if t.log != nil {
+		t.log.Append(&OrderVended{
+			Products: append([]int{}, bucket[:pattern.NumberPopped]...),
+			Patterns: copyPatterns([]*PopPattern{pattern}),
+		})
+	}
+
+	return true, nil
+}
+
+// Checks that the buckets are as the last patterns popping them left them, so that
+// putting the products back restores the exact bucket contents from before.
+func (t *Transaction) checkUndo(entries []*undoEntry) error {
+	checked := map[int]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if checked[entry.Index] {
+			continue
+		}
+		checked[entry.Index] = true
+
+		bucket := (*t.vendingMachine)[entry.Index]
+		if len(bucket) != len(entry.Kept) || !isPrefix(entry.Kept, bucket) {
+			return TransactionConflictErr
+		}
+	}
+
+	return nil
+}
+
+// Undo puts back the products of the last popped pattern, returning false when there
+// was nothing to put back. It returns TransactionConflictErr, putting nothing back,
+// when the bucket changed since the pattern was popped.
+func (t *Transaction) Undo() (bool, error) {
+	if t.closed {
+		return false, TransactionClosedErr
+	}
+	if len(t.undoLog) == 0 {
+		return false, nil
+	}
+
+	entry := t.undoLog[len(t.undoLog)-1]
+	if err := t.checkUndo([]*undoEntry{entry}); err != nil {
+		return false, err
+	}
+	t.undoLog = t.undoLog[:len(t.undoLog)-1]
+
+	bucket := (*t.vendingMachine)[entry.Index]
+	restored := make([]int, 0, len(entry.Popped)+len(bucket))
+	restored = append(restored, entry.Popped...)
+	(*t.vendingMachine)[entry.Index] = append(restored, bucket...)
+	if t.log != nil {
+		t.log.Append(&ProductsReturned{Bucket: entry.Index, Products: append([]int{}, entry.Popped...)})
+	}
+
+	return true, nil
+}
+
+// Commit pops the patterns that are left and closes the transaction. When a pattern
+// can't be popped the transaction stays open, so that it can be rolled back.
+func (t *Transaction) Commit() error {
+	for {
+		stepped, err := t.Step()
+		if err != nil {
+			return err
+		}
+		if !stepped {
+			break
+		}
+	}
+	t.closed = true
+
+	return nil
+}
+
+// Rollback puts back everything the transaction popped, last pattern first, and
+// closes the transaction. When one of the buckets changed since it was popped nothing
+// is put back and TransactionConflictErr is returned, the transaction staying open.
+func (t *Transaction) Rollback() error {
+	if t.closed {
+		return TransactionClosedErr
+	}
+	if err := t.checkUndo(t.undoLog); err != nil {
+		return err
+	}
+
+	for {
+		undone, err := t.Undo()
+		if err != nil {
+			return err
+		}
+		if !undone {
+			break
+		}
+	}
+	t.closed = true
+
+	return nil
+}
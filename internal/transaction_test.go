@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestTransaction_Commit(t *testing.T) {
+	vendingMachine := [][]int{{2}, {5, 1, 2}}
+	products := []int{5, 2, 1}
+
+	transaction, err := BeginOrder(&vendingMachine, &products, FindFirstPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Pending() != 3 || areEqualInt(vendingMachine[1], []int{5, 1, 2}) == false {
+		t.Fatal("Begin must not pop")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(vendingMachine[0]) != 0 || areEqualInt(vendingMachine[1], []int{2}) == false {
+		t.Fatalf("Invalid vending machine %+v", vendingMachine)
+	}
+	if err := transaction.Rollback(); err != TransactionClosedErr {
+		t.Fatalf("Expected closed transaction, got %v", err)
+	}
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	vendingMachine := [][]int{{2}, {5, 1, 2}}
+	patterns := []*PopPattern{
+		{Index: 1, NumberPopped: 1},
+		{Index: 0, NumberPopped: 1},
+		{Index: 1, NumberPopped: 1},
+	}
+
+	transaction, err := Begin(&vendingMachine, &patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if stepped, err := transaction.Step(); !stepped || err != nil {
+			t.Fatalf("Expected step %d, got %v", i, err)
+		}
+	}
+	if transaction.Applied() != 2 || len(vendingMachine[0]) != 0 || areEqualInt(vendingMachine[1], []int{1, 2}) == false {
+		t.Fatalf("Invalid vending machine %+v", vendingMachine)
+	}
+
+	if undone, err := transaction.Undo(); !undone || err != nil {
+		t.Fatalf("Expected undo, got %v", err)
+	}
+	if areEqualInt(vendingMachine[0], []int{2}) == false {
+		t.Fatalf("Invalid vending machine after undo %+v", vendingMachine)
+	}
+
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if areEqualInt(vendingMachine[0], []int{2}) == false || areEqualInt(vendingMachine[1], []int{5, 1, 2}) == false {
+		t.Fatalf("Invalid vending machine after rollback %+v", vendingMachine)
+	}
+	if _, err := transaction.Step(); err != TransactionClosedErr {
+		t.Fatalf("Expected closed transaction, got %v", err)
+	}
+}
+
+func TestTransaction_StepMismatch(t *testing.T) {
+	vendingMachine := [][]int{{1, 2}, {3}}
+	patterns := []*PopPattern{{Index: 0, NumberPopped: 1}}
+
+	transaction, err := Begin(&vendingMachine, &patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buckets := transaction.Buckets(); len(buckets) != 1 || !buckets[0] {
+		t.Fatalf("Expected bucket 0 to be reserved, got %+v", buckets)
+	}
+
+	// Something else vended the planned product
+	vendingMachine[0] = vendingMachine[0][1:]
+
+	if _, err := transaction.Step(); err != StepMismatchErr {
+		t.Fatalf("Expected step mismatch, got %v", err)
+	}
+	if transaction.Applied() != 0 || areEqualInt(vendingMachine[0], []int{2}) == false {
+		t.Fatalf("Invalid vending machine after mismatch %+v", vendingMachine)
+	}
+
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if !transaction.Closed() || len(transaction.Buckets()) != 0 {
+		t.Fatalf("Expected no reserved buckets once closed, got %+v", transaction.Buckets())
+	}
+}
+
+func TestTransaction_RollbackConflict(t *testing.T) {
+	vendingMachine := [][]int{{1, 2}, {3}}
+	patterns := []*PopPattern{{Index: 0, NumberPopped: 1}, {Index: 1, NumberPopped: 1}}
+
+	transaction, err := Begin(&vendingMachine, &patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if stepped, err := transaction.Step(); !stepped || err != nil {
+			t.Fatalf("Expected step %d, got %v", i, err)
+		}
+	}
+
+	// Bucket 0 was replaced after it was popped
+	vendingMachine[0] = []int{4, 4}
+
+	if err := transaction.Rollback(); err != TransactionConflictErr {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+	if transaction.Closed() || areEqualInt(vendingMachine[0], []int{4, 4}) == false || len(vendingMachine[1]) != 0 {
+		t.Fatalf("Invalid vending machine after conflict %+v", vendingMachine)
+	}
+
+	if undone, err := transaction.Undo(); !undone || err != nil {
+		t.Fatalf("Expected undo of bucket 1, got %v", err)
+	}
+	if areEqualInt(vendingMachine[1], []int{3}) == false {
+		t.Fatalf("Invalid vending machine after undo %+v", vendingMachine)
+	}
+	if _, err := transaction.Undo(); err != TransactionConflictErr {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+	if areEqualInt(vendingMachine[0], []int{4, 4}) == false {
+		t.Fatalf("Invalid vending machine after conflict %+v", vendingMachine)
+	}
+}
+
+func TestBegin_Invalid(t *testing.T) {
+	vendingMachine := [][]int{{2}, {5, 1, 2}}
+
+	data := []struct {
+		scenario string
+		patterns []*PopPattern
+	}{
+		{
+			scenario: "More than the bucket has",
+			patterns: []*PopPattern{{Index: 1, NumberPopped: 2}, {Index: 1, NumberPopped: 2}},
+		},
+		{
+			scenario: "No such bucket",
+			patterns: []*PopPattern{{Index: 2, NumberPopped: 1}},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			if _, err := Begin(&vendingMachine, &d.patterns); err != InvalidArgument {
+				t.Fatalf("Expected invalid argument, got %v", err)
+			}
+		})
+	}
+}
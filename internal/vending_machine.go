@@ -3,7 +3,6 @@ package internal
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 )
 
@@ -74,33 +73,23 @@ func CreateFromString(str string) (*[][]int, error) {
 
 	buckets := strings.Split(str, ";")
 
-	for _, bucket := range buckets {
-		bucketProducts := strings.Split(bucket, ",")
-		parsedBucketProducts := []int{}
-
-		for _, product := range bucketProducts {
-			parsedProduct, err := strconv.Atoi(product)
-			if err != nil {
-				return nil, InvalidArgument
-			}
-			parsedBucketProducts = append(parsedBucketProducts, parsedProduct)
+	offset := 0
+	for i, bucket := range buckets {
+		parsedBucketProducts, err := parseProducts(str, bucket, i, offset)
+		if err != nil {
+			return nil, err
 		}
 		matrix = append(matrix, parsedBucketProducts)
+		offset += len(bucket) + 1
 	}
 
 	return &matrix, nil
 }
 
 func ParseInput(input string) (*[]int, error) {
-	parsedInput := strings.Split(input, ",")
-
-	products := []int{}
-	for _, p := range parsedInput {
-		parsedProduct, err := strconv.Atoi(p)
-		if err != nil {
-			return nil, InvalidArgument
-		}
-		products = append(products, parsedProduct)
+	products, err := parseProducts(input, input, -1, 0)
+	if err != nil {
+		return nil, err
 	}
 
 	return &products, nil
@@ -154,6 +143,23 @@ func PopByPattern(vendingMachine *[][]int, patterns *[]*PopPattern) {
 	}
 }
 
+// CheckPatterns returns InvalidArgument when one of the patterns pops a bucket that
+// does not exist, or the patterns together pop more than a bucket has.
+func CheckPatterns(vendingMachine *[][]int, patterns *[]*PopPattern) error {
+	popped := make([]int, len(*vendingMachine))
+	for _, pattern := range *patterns {
+		if pattern.Index < 0 || pattern.Index >= len(*vendingMachine) || pattern.NumberPopped < 0 {
+			return InvalidArgument
+		}
+		popped[pattern.Index] += pattern.NumberPopped
+		if popped[pattern.Index] > len((*vendingMachine)[pattern.Index]) {
+			return InvalidArgument
+		}
+	}
+
+	return nil
+}
+
 func cutIntFromSlice(slice *[]int, index int) {
 	*slice = append((*slice)[:index], (*slice)[index+1:]...)
 }
@@ -270,7 +276,8 @@ func FindFirstNoOrderPattern(possibleSlice *[]*PossibleBucketSlice, products *[]
 		}
 	}
 
-	if currentPopPattern != nil && currentPopPattern.NumberPopped == 0 {
+	// Nothing was found to track
+	if currentPopPattern == nil || currentPopPattern.NumberPopped == 0 {
 		return nil
 	}
 	appended := append([]*PopPattern{currentPopPattern}, pops...)
@@ -350,6 +357,11 @@ func FindFirstPattern(possibleSlice *[]*PossibleBucketSlice, products *[]int) *[
 }
 
 func FindCumulativePopPattern(vendingMachine *[][]int, products *[]int, fn PatternFunc) (*[]*PopPattern, error) {
+	// An empty order is vended without popping anything
+	if len(*products) == 0 {
+		return &[]*PopPattern{}, nil
+	}
+
 	var possibleSlices []*PossibleBucketSlice
 
 	for i, bucket := range *vendingMachine {
@@ -366,21 +378,55 @@ func FindCumulativePopPattern(vendingMachine *[][]int, products *[]int, fn Patte
 
 		patterns := fn(&possibleSlices, products)
 		if patterns != nil {
-			return patterns, nil
+			return toMachinePatterns(possibleSlices, patterns), nil
 		}
 	}
 
 	return nil, ImpossibleErr
 }
 
+// Scan every bucket of the vending machine at once, skipping the ones that can't
+// contribute to the products.
+func scanPossibleSlices(vendingMachine *[][]int, products *[]int) []*PossibleBucketSlice {
+	var possibleSlices []*PossibleBucketSlice
+
+	for i, bucket := range *vendingMachine {
+		slice := ScanBucketSlice(bucket, products)
+		if len(*slice) == 0 {
+			continue
+		}
+		possibleSlices = append(possibleSlices, &PossibleBucketSlice{
+			Index:  i,
+			Values: *slice,
+		})
+	}
+
+	return possibleSlices
+}
+
+// Pattern functions index into the possible slices, which skip buckets that have
+// nothing to offer, so the indexes need to be mapped back to the vending machine
+// buckets before popping.
+func toMachinePatterns(possibleSlices []*PossibleBucketSlice, patterns *[]*PopPattern) *[]*PopPattern {
+	mapped := make([]*PopPattern, 0, len(*patterns))
+	for _, pattern := range *patterns {
+		mapped = append(mapped, &PopPattern{
+			Index:        possibleSlices[pattern.Index].Index,
+			NumberPopped: pattern.NumberPopped,
+		})
+	}
+
+	return &mapped
+}
+
 func FindAndPopByOrder(vendingMachine *[][]int, products *[]int, fn PatternFunc) error {
 	patterns, err := FindCumulativePopPattern(vendingMachine, products, fn)
+	if err == ImpossibleErr || (err == nil && patterns == nil) {
+		return Explain(vendingMachine, products)
+	}
 	if err != nil {
 		return err
 	}
-	if patterns == nil {
-		return ImpossibleErr
-	}
 
 	PopByPattern(vendingMachine, patterns)
 
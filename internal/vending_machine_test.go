@@ -494,6 +494,66 @@ func TestFindCumulativePopPattern_NoOrder(t *testing.T) {
 	}
 }
 
+func TestFindCumulativePopPattern_SkippedBuckets(t *testing.T) {
+	data := []struct {
+		scenario        string
+		fn              PatternFunc
+		vendingMachine  [][]int
+		products        []int
+		expectedPattern []*PopPattern
+	}{
+		{
+			scenario:        "First pattern",
+			fn:              FindFirstPattern,
+			vendingMachine:  [][]int{{9}, {1, 2}, {9}, {3}},
+			products:        []int{1, 2, 3},
+			expectedPattern: []*PopPattern{{Index: 1, NumberPopped: 2}, {Index: 3, NumberPopped: 1}},
+		},
+		{
+			scenario:        "First no order pattern",
+			fn:              FindFirstNoOrderPattern,
+			vendingMachine:  [][]int{{9}, {2, 1}, {9}, {3}},
+			products:        []int{1, 2, 3},
+			expectedPattern: []*PopPattern{{Index: 1, NumberPopped: 2}, {Index: 3, NumberPopped: 1}},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			pattern, err := FindCumulativePopPattern(&d.vendingMachine, &d.products, d.fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := assertEqualPatterns(pattern, &d.expectedPattern); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := FindAndPopByOrder(&d.vendingMachine, &d.products, d.fn); err != nil {
+				t.Fatal(err)
+			}
+			expected := [][]int{{9}, {}, {9}, {}}
+			for i, bucket := range d.vendingMachine {
+				if !areEqualInt(bucket, expected[i]) {
+					t.Errorf("expected bucket %d to be %+v got %+v", i, expected[i], bucket)
+				}
+			}
+		})
+	}
+}
+
+func TestFindAndPopByOrder_NothingToTrack(t *testing.T) {
+	vendingMachine := [][]int{{1, 2}, {2}, {3}}
+	products := []int{1, 1}
+
+	err := FindAndPopByOrder(&vendingMachine, &products, FindFirstNoOrderPattern)
+	if !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("expected %v got %v", ImpossibleErr, err)
+	}
+	if !areEqualInt(vendingMachine[0], []int{1, 2}) {
+		t.Errorf("expected the vending machine to be left as it was got %+v", vendingMachine)
+	}
+}
+
 func areEqualInt(arr1 []int, arr2 []int) bool {
 	if len(arr1) != len(arr2) {
 		return false
@@ -1,54 +1,521 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"vending-machine-go/internal"
+	"os"
+	"time"
+
+	"github.com/doppelganger113/vending-machine-go/internal"
 )
 
-var inputString, vendingMachineString string
-var strict bool
+var inputString, vendingMachineString, costModel, equivalentString, catalogString string
+var command, manifestString, capacityString, diffFormat, statusString, expiryString string
+var schemaName, configPath, encoding, eventsPath, replayPath string
+var strict, complete, memoized, explain, partial, printSteps bool
+var split, jsonInput, jsonOutput bool
+var machineStrings []string
+var timeout time.Duration
 
 func init() {
 	flag.BoolVar(&strict, "strict", false, "strict input order")
+	flag.BoolVar(&complete, "complete", false, "exhaustive search that never misses a pattern, ignored when strict")
+	flag.BoolVar(&memoized, "memoized", false, "exhaustive search over all buckets at once, for large vending machines")
+	flag.BoolVar(&explain, "explain", false, "explain why the order is impossible")
+	flag.BoolVar(&partial, "partial", false, "vend as much of the order as possible instead of refusing it")
+	flag.DurationVar(&timeout, "timeout", 0, "give up on the search after the duration, as in 2s")
+	flag.BoolVar(&printSteps, "steps", false, "print the vend steps one pop at a time")
+	flag.StringVar(&equivalentString, "equivalent", "", "groups of interchangeable products by preference, as in 5,7,9;3,4")
+	flag.StringVar(&catalogString, "catalog", "", "products as sku,name,price,category;... so that orders and buckets use SKUs")
+	flag.StringVar(&capacityString, "capacity", "", "most products of each bucket for restock, as in 10,10,5, 0 for no limit")
+	flag.StringVar(&statusString, "status", "", "status of each bucket as in active,jammed,maintenance, only active buckets vend")
+	flag.StringVar(&expiryString, "expiry", "", "expiry date of each product like the buckets, as in 2021-05-01,;2021-06-01, empty when it does not expire")
+	flag.StringVar(&diffFormat, "diff", "", "print what changed in the vending machine, as text or json")
+	flag.BoolVar(&split, "split", false, "split the order over several vending machines when no single one can serve it")
+	flag.StringVar(&costModel, "cost", "", "pick the cheapest pattern by cost model: buckets, switches or depth")
+	flag.BoolVar(&jsonInput, "json-input", false, "read the order and the vending machine as JSON documents")
+	flag.BoolVar(&jsonOutput, "json-output", false, "write the plan and the vending machine as JSON documents")
+	flag.StringVar(&schemaName, "schema", "", "print the JSON schema of a machine, order or plan")
+	flag.StringVar(&encoding, "encode", "", "write the vending machine in the bucket encoding, plain or compact as in 1x3,2")
+	flag.StringVar(&configPath, "config", "", "read the vending machine from a TOML file instead of the buckets argument")
+	flag.StringVar(&eventsPath, "events", "", "append what changed in the vending machine to the file, one JSON event per line")
+	flag.StringVar(&replayPath, "replay", "", "replay the JSON events of the file onto the vending machine first")
 	flag.Parse()
 
+	if len(schemaName) > 0 {
+		return
+	}
+
 	args := flag.Args()
+	// The configuration file stands for the vending machine argument
+	if len(configPath) > 0 {
+		args = append(args, "")
+	}
+	if len(args) > 0 && args[0] == "restock" {
+		if len(args) < 3 {
+			log.Fatal("Invalid number of arguments. Expecting 'restock' 'manifest' 'vending_machine'")
+		}
+		command = args[0]
+		manifestString = args[1]
+		vendingMachineString = args[2]
+		return
+	}
 	if len(args) < 2 {
 		log.Fatal("Invalid number of arguments. Expecting 'input' 'vending_machine'")
 	}
 	inputString = args[0]
 	vendingMachineString = args[1]
+	machineStrings = args[1:]
 }
 
 func getPattern() internal.PatternFunc {
 	if strict == true {
 		return internal.FindFirstPattern
 	}
+	if complete == true {
+		return internal.FindBacktrackingPattern
+	}
 	return internal.FindFirstNoOrderPattern
 }
 
+func getContextPattern() internal.ContextPatternFunc {
+	if strict == false && complete == true {
+		return internal.FindBacktrackingPatternContext
+	}
+	return internal.WithContext(getPattern())
+}
+
+type flagSet struct {
+	name string
+	set  bool
+}
+
+// Returns InvalidArgument naming the first of the other flags that is set, none of
+// them can be combined with what is named, a flag as in -partial or a mode
+func checkNotCombined(name string, others []flagSet) error {
+	for _, other := range others {
+		if other.set {
+			return fmt.Errorf("%w: %s can't be combined with -%s", internal.InvalidArgument, name, other.name)
+		}
+	}
+
+	return nil
+}
+
+// Finds the pop patterns of the order with the search the flags pick, printing what
+// the search has to tell on the way
+func findPatterns(vendingMachine *[][]int, products *[]int) (*[]*internal.PopPattern, error) {
+	if partial == true {
+		// Partial vends have their own search, in any order of products
+		err := checkNotCombined("-partial", []flagSet{
+			{"strict", strict},
+			{"complete", complete},
+			{"memoized", memoized},
+			{"timeout", timeout > 0},
+			{"cost", len(costModel) > 0},
+			{"equivalent", len(equivalentString) > 0},
+			{"expiry", len(expiryString) > 0},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patterns, vended, remainder := internal.FindPartialPopPattern(vendingMachine, products)
+		fmt.Printf("Vended %+v\n", *vended)
+		fmt.Printf("Not vended %+v\n", *remainder)
+		return patterns, nil
+	}
+
+	if len(equivalentString) > 0 {
+		groups, err := internal.CreateFromString(equivalentString)
+		if err != nil {
+			return nil, err
+		}
+		equivalences, err := internal.NewEquivalences(groups)
+		if err != nil {
+			return nil, err
+		}
+		patterns, substitutions, err := internal.FindSubstitutePopPattern(vendingMachine, products, equivalences)
+		if err != nil {
+			return nil, err
+		}
+		for _, substitution := range *substitutions {
+			fmt.Printf("Substituted %d with %d\n", substitution.Requested, substitution.Used)
+		}
+		return patterns, nil
+	}
+
+	if len(expiryString) > 0 {
+		expiries, err := internal.ParseExpiries(expiryString)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if report := internal.FindExpired(vendingMachine, expiries, now); len(report.Expired) > 0 {
+			fmt.Print(report)
+		}
+		patterns, err := internal.FindFreshPopPattern(vendingMachine, expiries, products, now)
+		if err == internal.ImpossibleErr {
+			return nil, internal.Explain(internal.Fresh(vendingMachine, expiries, now), products)
+		}
+		return patterns, err
+	}
+
+	if len(costModel) > 0 {
+		model, ok := internal.CostModels[costModel]
+		if !ok {
+			return nil, internal.InvalidArgument
+		}
+		return internal.FindCheapestPopPattern(vendingMachine, products, model)
+	}
+
+	if memoized == true {
+		return internal.FindMemoizedPopPattern(vendingMachine, products)
+	}
+
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return internal.FindCumulativePopPatternContext(ctx, vendingMachine, products, getContextPattern())
+	}
+
+	return internal.FindCumulativePopPattern(vendingMachine, products, getPattern())
+}
+
+func vend(vendingMachine *[][]int, products *[]int, events *internal.EventLog) error {
+	patterns, err := findPatterns(vendingMachine, products)
+	if err == internal.ImpossibleErr {
+		return internal.Explain(vendingMachine, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput == true {
+		encoded, err := internal.PlanToJSON(patterns)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	}
+
+	if printSteps == true {
+		steps, err := internal.PatternsToSteps(vendingMachine, patterns)
+		if err != nil {
+			return err
+		}
+		for _, step := range *steps {
+			step.Print()
+		}
+		return events.PopBySteps(vendingMachine, steps)
+	}
+
+	return events.PopByPattern(vendingMachine, patterns)
+}
+
+// Replays the events of the -replay file onto the vending machine, returning the log
+// the run appends to
+func replayEvents(vendingMachine *[][]int) (*internal.EventLog, error) {
+	if len(replayPath) == 0 {
+		return &internal.EventLog{}, nil
+	}
+
+	file, err := os.Open(replayPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := internal.LoadEvents(vendingMachine, file); err != nil {
+		return nil, err
+	}
+
+	return &internal.EventLog{}, nil
+}
+
+// Appends the events of the run to the -events file
+func writeEvents(events *internal.EventLog) error {
+	if len(eventsPath) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(eventsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := events.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func printDiff(before *[][]int, after *[][]int) error {
+	diff, err := internal.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	switch diffFormat {
+	case "text":
+		fmt.Print(diff)
+	case "json":
+		encoded, err := diff.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	default:
+		return internal.InvalidArgument
+	}
+
+	return nil
+}
+
+// Usage:
+// cmd restock manifest buckets
+func restock() error {
+	var vendingMachine *[][]int
+	capacities := &[]int{}
+	if len(configPath) > 0 {
+		config, err := internal.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		vendingMachine = config.Machine.VendingMachine()
+		capacities = &config.Machine.Capacities
+	} else {
+		parsed, err := internal.CreateFromString(vendingMachineString)
+		if err != nil {
+			return err
+		}
+		vendingMachine = parsed
+	}
+	events, err := replayEvents(vendingMachine)
+	if err != nil {
+		return err
+	}
+	restocks, err := internal.ParseRestockManifest(manifestString)
+	if err != nil {
+		return err
+	}
+	if len(capacityString) > 0 {
+		capacities, err = internal.ParseInput(capacityString)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := events.RestockBuckets(vendingMachine, capacities, restocks); err != nil {
+		return err
+	}
+	internal.PrintPretty(vendingMachine)
+
+	return writeEvents(events)
+}
+
+// Usage:
+// cmd products buckets buckets...
+func fleet() error {
+	// Machines of the fleet are only searched like FindCumulativePopPattern does
+	err := checkNotCombined("several vending machines", []flagSet{
+		{"partial", partial},
+		{"memoized", memoized},
+		{"timeout", timeout > 0},
+		{"cost", len(costModel) > 0},
+		{"equivalent", len(equivalentString) > 0},
+		{"expiry", len(expiryString) > 0},
+		{"status", len(statusString) > 0},
+		{"catalog", len(catalogString) > 0},
+		{"config", len(configPath) > 0},
+		{"json-input", jsonInput},
+		{"json-output", jsonOutput},
+		{"steps", printSteps},
+		{"explain", explain},
+		{"diff", len(diffFormat) > 0},
+		{"encode", len(encoding) > 0},
+		{"events", len(eventsPath) > 0},
+		{"replay", len(replayPath) > 0},
+	})
+	if err != nil {
+		return err
+	}
+	products, err := internal.ParseInput(inputString)
+	if err != nil {
+		return err
+	}
+	fleet, err := internal.CreateFleetFromStrings(machineStrings)
+	if err != nil {
+		return err
+	}
+
+	fleetPatterns, err := internal.FindAndPopFleet(fleet, products, getPattern(), split)
+	if err != nil {
+		return err
+	}
+	for _, fleetPattern := range *fleetPatterns {
+		fleetPattern.Print()
+	}
+	for i, vendingMachine := range fleet.Machines {
+		fmt.Printf("Machine %d\n", i)
+		internal.PrintPretty(vendingMachine)
+	}
+
+	return nil
+}
+
+// Prints the error, with the input it was parsed from when the encoding is invalid
+func printError(err error) {
+	fmt.Println(err)
+	var parseErr *internal.ParseError
+	if errors.As(err, &parseErr) {
+		fmt.Print(parseErr.Caret())
+	}
+}
+
 // Usage:
 // cmd products buckets
 func main() {
-	parsedInput, err := internal.ParseInput(inputString)
+	if len(schemaName) > 0 {
+		schema, ok := internal.Schemas[schemaName]
+		if !ok {
+			fmt.Println(internal.InvalidArgument)
+			return
+		}
+		fmt.Println(schema)
+		return
+	}
+	if command == "restock" {
+		if err := restock(); err != nil {
+			printError(err)
+		}
+		return
+	}
+	if len(machineStrings) > 1 {
+		if err := fleet(); err != nil {
+			printError(err)
+		}
+		return
+	}
+
+	var catalog *internal.Catalog
+	var parsedInput *[]int
+	var vendingMachine *[][]int
+	var document *internal.MachineDocument
+	var err error
+
+	if len(configPath) > 0 {
+		var config *internal.Config
+		config, err = internal.LoadConfig(configPath)
+		if err == nil {
+			parsedInput, err = internal.ParseInput(inputString)
+		}
+		if err == nil {
+			document = config.Machine
+			vendingMachine = document.VendingMachine()
+			if len(config.Names) > 0 {
+				catalog = config.Catalog()
+			}
+		}
+	} else if jsonInput == true {
+		parsedInput, err = internal.OrderFromJSON([]byte(inputString))
+		if err == nil {
+			document, err = internal.MachineFromJSON([]byte(vendingMachineString))
+		}
+		if err == nil {
+			vendingMachine = document.VendingMachine()
+		}
+	} else if len(catalogString) > 0 {
+		catalog, err = internal.ParseCatalog(catalogString)
+		if err == nil {
+			parsedInput, err = internal.ParseSKUInput(inputString, catalog)
+		}
+		if err == nil {
+			vendingMachine, err = internal.CreateFromSKUString(vendingMachineString, catalog)
+		}
+	} else {
+		parsedInput, err = internal.ParseInput(inputString)
+		if err == nil {
+			vendingMachine, err = internal.CreateFromString(vendingMachineString)
+		}
+	}
+	if err != nil {
+		printError(err)
+		return
+	}
+	statuses, err := internal.ParseStatuses(statusString)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	if document != nil && len(statusString) == 0 {
+		statuses = &document.Statuses
+	}
 
-	vendingMachine, err := internal.CreateFromString(vendingMachineString)
+	events, err := replayEvents(vendingMachine)
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return
 	}
 
-	err = internal.FindAndPopByOrder(vendingMachine, parsedInput, getPattern())
+	before := internal.CopyVendingMachine(vendingMachine)
+	err = internal.WithStatus(vendingMachine, statuses, func(inService *[][]int) error {
+		return vend(inService, parsedInput, events)
+	})
 	if err != nil {
+		printError(err)
+		var impossibleErr *internal.ImpossibleError
+		if explain && errors.As(err, &impossibleErr) {
+			fmt.Print(impossibleErr.Report())
+		}
+		var timeoutErr *internal.TimeoutError
+		if errors.As(err, &timeoutErr) && timeoutErr.Partial != nil {
+			fmt.Println("Best partial pattern")
+			for _, pattern := range *timeoutErr.Partial {
+				pattern.Print()
+			}
+		}
+		return
+	}
+	if err := writeEvents(events); err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	internal.PrintPretty(vendingMachine)
+	if len(diffFormat) > 0 {
+		if err := printDiff(before, vendingMachine); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if len(encoding) > 0 {
+		if encoding != "plain" && encoding != "compact" {
+			fmt.Println(internal.InvalidArgument)
+			return
+		}
+		fmt.Println(internal.EncodeVendingMachine(vendingMachine, encoding == "compact"))
+		return
+	}
+	if jsonOutput == true {
+		if document == nil {
+			document = &internal.MachineDocument{}
+		}
+		document.Buckets = *vendingMachine
+		document.Statuses = *statuses
+		encoded, err := document.JSON()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(string(encoded))
+		return
+	}
+	if catalog != nil {
+		internal.PrintPrettyWithCatalog(vendingMachine, catalog, statuses)
+		return
+	}
+	internal.PrintPrettyWithStatus(vendingMachine, statuses)
 }
@@ -0,0 +1,578 @@
+// Package vending exposes the vending machine to other modules. The machine keeps its
+// own copy of the buckets, so nothing outside of it can change them, and the functions
+// of the internal package do the work underneath.
+package vending
+
+import (
+	"io"
+	"time"
+
+	"github.com/doppelganger113/vending-machine-go/internal"
+)
+
+var ImpossibleErr = internal.ImpossibleErr
+var InvalidArgument = internal.InvalidArgument
+
+var CapacityExceededErr = internal.CapacityExceededErr
+var TransactionClosedErr = internal.TransactionClosedErr
+var OutOfServiceErr = internal.OutOfServiceErr
+var HoldNotFoundErr = internal.HoldNotFoundErr
+var HoldExpiredErr = internal.HoldExpiredErr
+var HeldBucketErr = internal.HeldBucketErr
+var HeldProductsMissingErr = internal.HeldProductsMissingErr
+var HeldProductsExpiredErr = internal.HeldProductsExpiredErr
+var ReservedBucketErr = internal.ReservedBucketErr
+var StepMismatchErr = internal.StepMismatchErr
+var TransactionConflictErr = internal.TransactionConflictErr
+var ReplayMismatchErr = internal.ReplayMismatchErr
+
+// BucketStatus tells whether the bucket can vend, only active buckets can
+type BucketStatus = internal.BucketStatus
+
+const (
+	Active      = internal.Active
+	Jammed      = internal.Jammed
+	Maintenance = internal.Maintenance
+)
+
+// ImpossibleError explains why an order can't be vended, it matches ImpossibleErr
+type ImpossibleError = internal.ImpossibleError
+
+// RestockError tells which bucket would overflow, it matches CapacityExceededErr
+type RestockError = internal.RestockError
+
+// ExpiryReport lists the expired products and the pops that clear them
+type ExpiryReport = internal.ExpiryReport
+
+// Transaction pops a plan one pop at a time and can put everything back, on the
+// vending machine it began on
+type Transaction struct {
+	vm          *VendingMachine
+	transaction *internal.Transaction
+}
+
+// Number of pops that were done
+func (t *Transaction) Applied() int {
+	return t.transaction.Applied()
+}
+
+// Number of pops left to do
+func (t *Transaction) Pending() int {
+	return t.transaction.Pending()
+}
+
+// Closed tells whether the transaction was committed or rolled back.
+func (t *Transaction) Closed() bool {
+	return t.transaction.Closed()
+}
+
+// Step pops the next pop of the plan, returning false when there was none left, or
+// StepMismatchErr popping nothing when the bucket is not as it was planned.
+func (t *Transaction) Step() (bool, error) {
+	t.vm.ownBuckets()
+
+	return t.transaction.Step()
+}
+
+// Undo puts back the products of the last pop, returning false when there was nothing
+// to put back, or TransactionConflictErr putting nothing back when the bucket changed
+// since.
+func (t *Transaction) Undo() (bool, error) {
+	t.vm.ownBuckets()
+
+	return t.transaction.Undo()
+}
+
+// Commit pops what is left of the plan and closes the transaction, which stays open
+// when a pop fails so that it can be rolled back.
+func (t *Transaction) Commit() error {
+	t.vm.ownBuckets()
+
+	return t.transaction.Commit()
+}
+
+// Rollback puts back everything the transaction popped and closes it, or returns
+// TransactionConflictErr putting nothing back when one of the buckets changed since.
+func (t *Transaction) Rollback() error {
+	t.vm.ownBuckets()
+
+	return t.transaction.Rollback()
+}
+
+// Event is a change of the buckets, as recorded in the log of the vending machine
+type Event = internal.Event
+
+// Pop takes Count products from the front of the bucket
+type Pop struct {
+	Bucket int
+	Count  int
+}
+
+// Plan is applied pop by pop, in order
+type Plan []Pop
+
+// VendingMachine keeps its state private and is a value, a copy of it changes on its
+// own. Copies share the slices of the state, which are never changed in place, a
+// change replaces the slices it touches. The buckets of a transaction that is open
+// when the copy is made stay reserved in the copy until the transaction is closed.
+type VendingMachine struct {
+	// Popping or restocking a bucket replaces it, only this slice is changed in place,
+	// after ownBuckets copied it
+	buckets [][]int
+	// Most products each bucket can hold, 0 for no limit
+	capacities []int
+	statuses   []BucketStatus
+	// Expiry dates of the products ever stocked in each bucket, the last ones
+	// belong to the products the bucket has, so pops don't need to change them
+	expiries [][]time.Time
+	// Copied before it changes, except for dropping the expired holds
+	holds *internal.Holds
+	// Transactions that began, the buckets of the open ones are reserved
+	transactions []*Transaction
+	// Every change of the buckets, oldest first
+	events internal.EventLog
+}
+
+func noExpiries(buckets [][]int) [][]time.Time {
+	expiries := make([][]time.Time, len(buckets))
+	for i, bucket := range buckets {
+		expiries[i] = make([]time.Time, len(bucket))
+	}
+
+	return expiries
+}
+
+// New creates a vending machine from a copy of the buckets, front of each bucket first.
+func New(buckets [][]int) *VendingMachine {
+	return &VendingMachine{
+		buckets:    *internal.CopyVendingMachine(&buckets),
+		capacities: make([]int, len(buckets)),
+		statuses:   make([]BucketStatus, len(buckets)),
+		expiries:   noExpiries(buckets),
+		holds:      internal.NewHolds(),
+	}
+}
+
+// Parse creates a vending machine from its encoding, as in 1,2,3;4,5
+func Parse(str string) (*VendingMachine, error) {
+	buckets, err := internal.CreateFromString(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(*buckets), nil
+}
+
+// Replay creates the vending machine from its encoding, as Parse does, and replays
+// the log WriteEvents wrote. Capacities, statuses, expiry dates and holds are not in
+// the log and start anew.
+func Replay(str string, reader io.Reader) (*VendingMachine, error) {
+	buckets, err := internal.CreateFromString(str)
+	if err != nil {
+		return nil, err
+	}
+	events, err := internal.LoadEvents(buckets, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := New(*buckets)
+	vm.events = *events
+
+	return vm, nil
+}
+
+// String encodes the buckets like Parse reads them, empty buckets included, as in
+// 1,2;;3
+func (vm *VendingMachine) String() string {
+	return internal.EncodeVendingMachine(&vm.buckets, false)
+}
+
+// Buckets returns a copy of the buckets
+func (vm *VendingMachine) Buckets() [][]int {
+	return *internal.CopyVendingMachine(&vm.buckets)
+}
+
+// Events returns the changes of the buckets since the vending machine was created,
+// oldest first.
+func (vm *VendingMachine) Events() []Event {
+	return vm.events.Events()
+}
+
+// WriteEvents writes the changes of the buckets one JSON document per line, which
+// Replay reads back.
+func (vm *VendingMachine) WriteEvents(writer io.Writer) error {
+	return vm.events.Encode(writer)
+}
+
+// Clone returns a copy of the vending machine, as copying the value does, except that
+// the buckets of the open transactions are not reserved in the clone.
+func (vm *VendingMachine) Clone() *VendingMachine {
+	clone := *vm
+	clone.transactions = nil
+
+	return &clone
+}
+
+// The buckets are shared with the copies of the vending machine until they change
+func (vm *VendingMachine) ownBuckets() {
+	vm.buckets = append([][]int{}, vm.buckets...)
+}
+
+// Peek returns the product in front of the bucket, false when there is none.
+func (vm *VendingMachine) Peek(bucket int) (int, bool) {
+	if bucket < 0 || bucket >= len(vm.buckets) || len(vm.buckets[bucket]) == 0 {
+		return 0, false
+	}
+
+	return vm.buckets[bucket][0], true
+}
+
+// Plan finds how the order can be vended without changing the vending machine,
+// skipping the buckets that are out of service or have held products and never
+// vending or popping past an expired product. Order of products does not matter.
+func (vm *VendingMachine) Plan(order []int) (Plan, error) {
+	return vm.PlanAt(order, time.Now())
+}
+
+// PlanAt is Plan at the given time. When products have expiry dates the ones that
+// expire soonest are vended first.
+func (vm *VendingMachine) PlanAt(order []int, now time.Time) (Plan, error) {
+	expiries := vm.expiryDates()
+	available := vm.available(expiries, now)
+
+	patterns, err := internal.FindFreshPopPattern(available, expiries, &order, now)
+	if err == internal.ImpossibleErr {
+		return nil, internal.Explain(available, &order)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toPlan(patterns), nil
+}
+
+// PlanStrict is Plan where products are vended in the order they are listed.
+func (vm *VendingMachine) PlanStrict(order []int) (Plan, error) {
+	available := vm.available(vm.expiryDates(), time.Now())
+	patterns, err := internal.FindCumulativePopPattern(available, &order, internal.FindFirstPattern)
+	if err == internal.ImpossibleErr {
+		return nil, internal.Explain(available, &order)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toPlan(patterns), nil
+}
+
+// Apply pops the plan, or returns InvalidArgument leaving the vending machine as it
+// was when the plan pops more than a bucket has, and OutOfServiceErr when it pops a
+// bucket that is out of service.
+func (vm *VendingMachine) Apply(plan Plan) error {
+	patterns := toPatterns(plan)
+	if err := internal.CheckPatterns(&vm.buckets, patterns); err != nil {
+		return err
+	}
+	if err := vm.checkPops(patterns); err != nil {
+		return err
+	}
+	vm.ownBuckets()
+
+	return vm.events.PopByPattern(&vm.buckets, patterns)
+}
+
+// Begin reserves the plan, which is popped by the transaction with Step or Commit and
+// put back with Undo or Rollback. Until the transaction is closed its buckets can't be
+// planned, popped or restocked by anything else, which returns ReservedBucketErr.
+func (vm *VendingMachine) Begin(plan Plan) (*Transaction, error) {
+	patterns := toPatterns(plan)
+	if err := vm.checkPops(patterns); err != nil {
+		return nil, err
+	}
+
+	begun, err := vm.events.Begin(&vm.buckets, patterns)
+	if err != nil {
+		return nil, err
+	}
+	transaction := &Transaction{vm: vm, transaction: begun}
+	vm.transactions = append(append([]*Transaction{}, vm.transactions...), transaction)
+
+	return transaction, nil
+}
+
+// Buckets reserved by the open transactions, the closed ones are forgotten
+func (vm *VendingMachine) reserved() map[int]bool {
+	reserved := map[int]bool{}
+	open := []*Transaction{}
+	for _, transaction := range vm.transactions {
+		if transaction.Closed() {
+			continue
+		}
+		open = append(open, transaction)
+		for bucket := range transaction.transaction.Buckets() {
+			reserved[bucket] = true
+		}
+	}
+	vm.transactions = open
+
+	return reserved
+}
+
+func (vm *VendingMachine) checkPops(patterns *[]*internal.PopPattern) error {
+	if err := internal.CheckInService(&vm.statuses, patterns); err != nil {
+		return err
+	}
+	reserved := vm.reserved()
+	for _, pattern := range *patterns {
+		if pattern.NumberPopped > 0 && reserved[pattern.Index] {
+			return ReservedBucketErr
+		}
+	}
+
+	return vm.holdsAt(time.Now()).CheckNotHeld(patterns)
+}
+
+// The holds that did not expire by now, the expired ones are dropped from a copy so
+// that reading the vending machine does not change it
+func (vm *VendingMachine) holdsAt(now time.Time) *internal.Holds {
+	holds := vm.holds.Copy()
+	holds.Expire(now)
+
+	return holds
+}
+
+// Hold plans the order and keeps its products until the hold is fulfilled, released
+// or expires, returning the id of the hold. The zero time is a hold that does not
+// expire.
+func (vm *VendingMachine) Hold(order []int, expires time.Time) (int, Plan, error) {
+	now := time.Now()
+	plan, err := vm.PlanAt(order, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	vm.holds = vm.holds.Copy()
+	hold, err := vm.holds.Reserve(&vm.buckets, &order, toPatterns(plan), expires, now)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return hold.Id, plan, nil
+}
+
+// Release drops the hold, its products can be vended again.
+func (vm *VendingMachine) Release(id int) error {
+	vm.holds = vm.holds.Copy()
+
+	return vm.holds.Release(id)
+}
+
+// Fulfil pops the plan of the hold, returning HoldExpiredErr when it expired. The hold
+// is kept when one of its buckets went out of service, returning OutOfServiceErr, or
+// when one of its products expired, returning HeldProductsExpiredErr.
+func (vm *VendingMachine) Fulfil(id int) (Plan, error) {
+	return vm.FulfilAt(id, time.Now())
+}
+
+// FulfilAt is Fulfil at the given time.
+func (vm *VendingMachine) FulfilAt(id int, now time.Time) (Plan, error) {
+	vm.holds = vm.holds.Copy()
+	hold, err := vm.holds.Pending(id, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := internal.CheckInService(&vm.statuses, &hold.Patterns); err != nil {
+		return nil, err
+	}
+	if internal.PopsExpired(vm.expiryDates(), &hold.Patterns, now) {
+		return nil, HeldProductsExpiredErr
+	}
+
+	vm.ownBuckets()
+	hold, err = vm.holds.Fulfil(&vm.buckets, id, now)
+	if err != nil {
+		return nil, err
+	}
+	vm.events.Append(&internal.OrderVended{Products: hold.Products, Patterns: hold.Patterns})
+
+	return toPlan(&hold.Patterns), nil
+}
+
+// Status of the bucket, buckets that don't exist are never active
+func (vm *VendingMachine) Status(bucket int) BucketStatus {
+	if bucket < 0 || bucket >= len(vm.statuses) {
+		return Maintenance
+	}
+
+	return vm.statuses[bucket]
+}
+
+// SetStatus takes the bucket out of service when it is jammed or under maintenance,
+// and back in service when it is active again.
+func (vm *VendingMachine) SetStatus(bucket int, status BucketStatus) error {
+	statuses := append([]BucketStatus{}, vm.statuses...)
+	if err := internal.SetBucketStatus(&vm.buckets, &statuses, bucket, status); err != nil {
+		return err
+	}
+	vm.statuses = statuses
+
+	return nil
+}
+
+// Capacity of the bucket, 0 when there is no limit
+func (vm *VendingMachine) Capacity(bucket int) int {
+	if bucket < 0 || bucket >= len(vm.capacities) {
+		return 0
+	}
+
+	return vm.capacities[bucket]
+}
+
+// SetCapacity limits the number of products of the bucket, 0 removes the limit. It
+// does not check the products already in the bucket, only the next restocks.
+func (vm *VendingMachine) SetCapacity(bucket int, capacity int) error {
+	if bucket < 0 || bucket >= len(vm.buckets) || capacity < 0 {
+		return internal.InvalidArgument
+	}
+	vm.capacities = append([]int{}, vm.capacities...)
+	vm.capacities[bucket] = capacity
+
+	return nil
+}
+
+// Restock adds the products to the back of the bucket, returning a *RestockError when
+// they don't fit.
+func (vm *VendingMachine) Restock(bucket int, products []int) error {
+	return vm.restock(&internal.Restock{Bucket: bucket, Products: products}, nil)
+}
+
+// RestockWithExpiry is Restock with the expiry date of each product, the zero time
+// for a product that does not expire.
+func (vm *VendingMachine) RestockWithExpiry(bucket int, products []int, expiries []time.Time) error {
+	if len(expiries) != len(products) {
+		return internal.InvalidArgument
+	}
+
+	return vm.restock(&internal.Restock{Bucket: bucket, Products: products}, expiries)
+}
+
+// Replace puts the products in the bucket instead of the ones it has, returning
+// HeldBucketErr when the bucket has held products.
+func (vm *VendingMachine) Replace(bucket int, products []int) error {
+	return vm.restock(&internal.Restock{Bucket: bucket, Products: products, Replace: true}, nil)
+}
+
+func (vm *VendingMachine) restock(restock *internal.Restock, expiries []time.Time) error {
+	if vm.reserved()[restock.Bucket] {
+		return ReservedBucketErr
+	}
+	if restock.Replace && vm.holdsAt(time.Now()).IsHeld(restock.Bucket) {
+		return HeldBucketErr
+	}
+	restock.Products = append([]int{}, restock.Products...)
+	restocks := []*internal.Restock{restock}
+
+	vm.ownBuckets()
+	if err := vm.events.RestockBuckets(&vm.buckets, &vm.capacities, &restocks); err != nil {
+		return err
+	}
+
+	// Only the dates of the products the bucket kept are needed, the bucket is not
+	// reserved so no transaction puts popped products back
+	kept := len(vm.buckets[restock.Bucket]) - len(restock.Products)
+	dates := vm.expiries[restock.Bucket]
+	if len(dates) > kept {
+		dates = dates[len(dates)-kept:]
+	}
+	if expiries == nil {
+		expiries = make([]time.Time, len(restock.Products))
+	}
+	restocked := make([]time.Time, 0, len(dates)+len(expiries))
+	restocked = append(restocked, dates...)
+	vm.expiries = append([][]time.Time{}, vm.expiries...)
+	vm.expiries[restock.Bucket] = append(restocked, expiries...)
+
+	return nil
+}
+
+// Expired lists the products that expired by the given time and the pops that take
+// them out.
+func (vm *VendingMachine) Expired(now time.Time) *ExpiryReport {
+	expiries := vm.expiryDates()
+
+	return internal.FindExpired(&vm.buckets, expiries, now)
+}
+
+// ClearExpired takes out the expired products, with the ones in front of them, as an
+// operator would by hand, so the status of the buckets does not matter.
+func (vm *VendingMachine) ClearExpired(now time.Time) *ExpiryReport {
+	expiries := vm.expiryDates()
+	vm.ownBuckets()
+	report := internal.ClearExpired(&vm.buckets, expiries, now)
+	for _, pattern := range report.Clearing {
+		vm.events.Append(&internal.ManuallyRemoved{Bucket: pattern.Index, Count: pattern.NumberPopped})
+	}
+
+	return report
+}
+
+// Expiry dates of the products the buckets have, front first. Products are only put
+// back in front of a bucket by the transaction that popped them, which keeps the
+// bucket reserved so that its dates can't be replaced meanwhile, but a product
+// without a date is still taken as one that does not expire rather than reading
+// past the dates.
+func (vm *VendingMachine) expiryDates() *[][]time.Time {
+	expiries := make([][]time.Time, len(vm.buckets))
+	for i, bucket := range vm.buckets {
+		dates := vm.expiries[i]
+		if len(dates) < len(bucket) {
+			dates = append(make([]time.Time, len(bucket)-len(dates)), dates...)
+		}
+		expiries[i] = dates[len(dates)-len(bucket):]
+	}
+
+	return &expiries
+}
+
+// The buckets as the solvers see them, without the buckets that are out of service,
+// held or reserved and cut before the first expired product.
+func (vm *VendingMachine) available(expiries *[][]time.Time, now time.Time) *[][]int {
+	available := *vm.holdsAt(now).Available(&vm.buckets, now)
+	for bucket := range vm.reserved() {
+		available[bucket] = nil
+	}
+
+	return internal.Fresh(internal.InService(&available, &vm.statuses), expiries, now)
+}
+
+// Vend plans the order and applies it.
+func (vm *VendingMachine) Vend(order []int) (Plan, error) {
+	plan, err := vm.Plan(order)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := vm.Apply(plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func toPlan(patterns *[]*internal.PopPattern) Plan {
+	plan := make(Plan, 0, len(*patterns))
+	for _, pattern := range *patterns {
+		plan = append(plan, Pop{Bucket: pattern.Index, Count: pattern.NumberPopped})
+	}
+
+	return plan
+}
+
+func toPatterns(plan Plan) *[]*internal.PopPattern {
+	patterns := make([]*internal.PopPattern, 0, len(plan))
+	for _, pop := range plan {
+		patterns = append(patterns, &internal.PopPattern{Index: pop.Bucket, NumberPopped: pop.Count})
+	}
+
+	return &patterns
+}
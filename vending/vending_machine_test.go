@@ -0,0 +1,625 @@
+package vending
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("1,a"); !errors.Is(err, InvalidArgument) {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+
+	vm, err := Parse("1,2;3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2}, {3}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+}
+
+func TestVendingMachine_String(t *testing.T) {
+	vm, err := Parse("1,2;;3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2}, {}, {3}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+
+	plan, err := vm.Vend(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 {
+		t.Fatalf("Expected an empty plan, got %+v", plan)
+	}
+
+	if _, err := vm.Vend([]int{3, 2, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if vm.String() != ";;" {
+		t.Fatalf("Invalid encoding %s", vm.String())
+	}
+	parsed, err := Parse(vm.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Buckets(), vm.Buckets()) {
+		t.Fatalf("Expected %+v, got %+v", vm.Buckets(), parsed.Buckets())
+	}
+}
+
+func TestVendingMachine_ValueSemantics(t *testing.T) {
+	buckets := [][]int{{1, 2}, {3}}
+	vm := New(buckets)
+
+	buckets[0][0] = 9
+	vm.Buckets()[1][0] = 9
+	copied := *vm
+	if _, err := copied.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Vend([]int{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Restock(1, []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SetStatus(0, Jammed); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SetCapacity(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := copied.Hold([]int{2}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2}, {4}}) || vm.Status(0) != Jammed {
+		t.Fatalf("Vending machine has changed %+v", vm.Buckets())
+	}
+	if !reflect.DeepEqual(copied.Buckets(), [][]int{{2}, {3}}) || copied.Status(0) != Active || copied.Capacity(1) != 0 {
+		t.Fatalf("Invalid copy %+v", copied.Buckets())
+	}
+	if len(vm.Events()) != 2 || len(copied.Events()) != 1 {
+		t.Fatalf("Expected events of their own, got %d and %d", len(vm.Events()), len(copied.Events()))
+	}
+	if err := vm.SetStatus(0, Active); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Vend([]int{1}); err != nil {
+		t.Fatalf("Expected the hold of the copy to leave the vending machine alone, got %v", err)
+	}
+	if _, err := copied.Fulfil(1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{2}, {4}}) || !reflect.DeepEqual(copied.Buckets(), [][]int{{}, {3}}) {
+		t.Fatalf("Invalid buckets %+v and %+v", vm.Buckets(), copied.Buckets())
+	}
+}
+
+func TestVendingMachine_CopyOpenTransaction(t *testing.T) {
+	vm := New([][]int{{1, 2}, {3}})
+	transaction, err := vm.Begin(Plan{{Bucket: 0, Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := *vm
+	clone := vm.Clone()
+	if _, err := transaction.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{2}, {3}}) || !reflect.DeepEqual(copied.Buckets(), [][]int{{1, 2}, {3}}) {
+		t.Fatalf("Invalid buckets %+v and %+v", vm.Buckets(), copied.Buckets())
+	}
+	if _, err := copied.Vend([]int{1}); err == nil {
+		t.Fatal("Expected the bucket of the transaction to stay reserved in the copy")
+	}
+	if _, err := clone.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copied.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{2}, {3}}) {
+		t.Fatalf("Vending machine has changed %+v", vm.Buckets())
+	}
+}
+
+func TestVendingMachine_Peek(t *testing.T) {
+	vm := New([][]int{{1, 2}, {}})
+
+	data := []struct {
+		scenario        string
+		bucket          int
+		expectedProduct int
+		expectedOk      bool
+	}{
+		{scenario: "Front product", bucket: 0, expectedProduct: 1, expectedOk: true},
+		{scenario: "Empty bucket", bucket: 1},
+		{scenario: "No such bucket", bucket: 2},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			product, ok := vm.Peek(d.bucket)
+			if product != d.expectedProduct || ok != d.expectedOk {
+				t.Fatalf("Expected %d %v, got %d %v", d.expectedProduct, d.expectedOk, product, ok)
+			}
+		})
+	}
+}
+
+func TestVendingMachine_Vend(t *testing.T) {
+	vm := New([][]int{
+		{1, 2, 3, 5, 5},
+		{2, 5, 4, 3, 1},
+		{3, 5, 4, 1, 1},
+		{5, 1, 1, 1, 1},
+	})
+
+	plan, err := vm.Vend([]int{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 2}, {Bucket: 2, Count: 3}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+	if !reflect.DeepEqual(vm.Buckets()[0], []int{3, 5, 5}) || !reflect.DeepEqual(vm.Buckets()[2], []int{1, 1}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+
+	_, err = vm.Vend([]int{4, 4})
+	var impossibleErr *ImpossibleError
+	if !errors.Is(err, ImpossibleErr) || !errors.As(err, &impossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+}
+
+func TestVendingMachine_PlanStrict(t *testing.T) {
+	vm := New([][]int{{2}, {5, 1, 2}})
+
+	plan, err := vm.PlanStrict([]int{5, 2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 1, Count: 1}, {Bucket: 0, Count: 1}, {Bucket: 1, Count: 1}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+}
+
+func TestVendingMachine_Apply(t *testing.T) {
+	data := []struct {
+		scenario        string
+		plan            Plan
+		expectedErr     error
+		expectedBuckets [][]int
+	}{
+		{
+			scenario:        "Same bucket twice",
+			plan:            Plan{{Bucket: 0, Count: 1}, {Bucket: 0, Count: 1}},
+			expectedBuckets: [][]int{{}, {3}},
+		},
+		{
+			scenario:        "More than the bucket has",
+			plan:            Plan{{Bucket: 1, Count: 1}, {Bucket: 0, Count: 2}, {Bucket: 0, Count: 1}},
+			expectedErr:     InvalidArgument,
+			expectedBuckets: [][]int{{1, 2}, {3}},
+		},
+		{
+			scenario:        "No such bucket",
+			plan:            Plan{{Bucket: 2, Count: 1}},
+			expectedErr:     InvalidArgument,
+			expectedBuckets: [][]int{{1, 2}, {3}},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.scenario, func(t *testing.T) {
+			vm := New([][]int{{1, 2}, {3}})
+			if err := vm.Apply(d.plan); err != d.expectedErr {
+				t.Fatalf("Expected error %v, got %v", d.expectedErr, err)
+			}
+			if !reflect.DeepEqual(vm.Buckets(), d.expectedBuckets) {
+				t.Fatalf("Expected buckets %+v, got %+v", d.expectedBuckets, vm.Buckets())
+			}
+		})
+	}
+}
+
+func TestVendingMachine_Restock(t *testing.T) {
+	vm := New([][]int{{1, 2}, {3}})
+	if err := vm.SetCapacity(0, 3); err != nil {
+		t.Fatal(err)
+	}
+	clone := vm.Clone()
+
+	products := []int{5}
+	if err := vm.Restock(0, products); err != nil {
+		t.Fatal(err)
+	}
+	products[0] = 9
+
+	err := vm.Restock(0, []int{5})
+	var restockErr *RestockError
+	if !errors.Is(err, CapacityExceededErr) || !errors.As(err, &restockErr) || restockErr.Size != 4 {
+		t.Fatalf("Expected capacity to be exceeded, got %v", err)
+	}
+	if err := vm.Replace(1, []int{4, 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2, 5}, {4, 4}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+	if clone.Capacity(0) != 3 || !reflect.DeepEqual(clone.Buckets(), [][]int{{1, 2}, {3}}) {
+		t.Fatalf("Clone has changed %+v", clone.Buckets())
+	}
+}
+
+func TestVendingMachine_Begin(t *testing.T) {
+	vm := New([][]int{{1, 2}, {3}})
+
+	plan, err := vm.Plan([]int{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction, err := vm.Begin(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{2}, {3}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2}, {3}}) {
+		t.Fatalf("Invalid buckets after rollback %+v", vm.Buckets())
+	}
+}
+
+func TestVendingMachine_BeginReserves(t *testing.T) {
+	vm := New([][]int{{1, 2}, {3}, {1}})
+
+	transaction, err := vm.Begin(Plan{{Bucket: 0, Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := vm.Plan([]int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 2, Count: 1}}) {
+		t.Fatalf("Expected the reserved bucket to be skipped, got %+v", plan)
+	}
+	if _, err := vm.Vend([]int{2}); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+	if err := vm.Apply(Plan{{Bucket: 0, Count: 1}}); err != ReservedBucketErr {
+		t.Fatalf("Expected reserved bucket, got %v", err)
+	}
+	if _, err := vm.Begin(Plan{{Bucket: 0, Count: 1}}); err != ReservedBucketErr {
+		t.Fatalf("Expected reserved bucket, got %v", err)
+	}
+	if err := vm.Replace(0, []int{4}); err != ReservedBucketErr {
+		t.Fatalf("Expected reserved bucket, got %v", err)
+	}
+	if _, _, err := vm.Hold([]int{2}, time.Time{}); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Vend([]int{2}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{}, {3}, {1}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+}
+
+func TestVendingMachine_RollbackKeepsExpiries(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	later := now.AddDate(0, 0, 7)
+
+	vm := New([][]int{{}, {1}})
+	if err := vm.RestockWithExpiry(0, []int{1, 1}, []time.Time{later, past}); err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := vm.Begin(Plan{{Bucket: 0, Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Replace(0, []int{2, 2, 2}); err != ReservedBucketErr {
+		t.Fatalf("Expected reserved bucket, got %v", err)
+	}
+	if err := transaction.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := vm.PlanAt([]int{1, 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 1}, {Bucket: 1, Count: 1}}) {
+		t.Fatalf("Expected the expired product to stay in bucket 0, got %+v", plan)
+	}
+
+	// A bucket longer than its dates is read as products that don't expire
+	vm.buckets[1] = append([]int{1}, vm.buckets[1]...)
+	if _, err := vm.PlanAt([]int{1, 1, 1}, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVendingMachine_Replay(t *testing.T) {
+	now := time.Now()
+	initial := "1,2;3;1"
+	vm, err := Parse(initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RestockWithExpiry(1, []int{4, 4}, []time.Time{now.AddDate(0, 0, -1), {}}); err != nil {
+		t.Fatal(err)
+	}
+	id, _, err := vm.Hold([]int{3}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Fulfil(id); err != nil {
+		t.Fatal(err)
+	}
+	vm.ClearExpired(now)
+	transaction, err := vm.Begin(Plan{{Bucket: 0, Count: 1}, {Bucket: 1, Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := transaction.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.Events()) != 8 {
+		t.Fatalf("Expected 8 events, got %d", len(vm.Events()))
+	}
+
+	var buffer bytes.Buffer
+	if err := vm.WriteEvents(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buffer.String()
+	replayed, err := Replay(initial, strings.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed.Buckets(), vm.Buckets()) || len(replayed.Events()) != 8 {
+		t.Fatalf("Invalid replay %+v, expected %+v", replayed.Buckets(), vm.Buckets())
+	}
+	if _, err := Replay("2,1;3;1", strings.NewReader(encoded)); !errors.Is(err, ReplayMismatchErr) {
+		t.Fatalf("Expected mismatch, got %v", err)
+	}
+}
+
+func TestVendingMachine_SetStatus(t *testing.T) {
+	vm := New([][]int{{1, 2}, {1}})
+	if err := vm.SetStatus(1, Jammed); err != nil {
+		t.Fatal(err)
+	}
+	if vm.Status(1) != Jammed || vm.Clone().Status(1) != Jammed {
+		t.Fatalf("Expected bucket 1 to be jammed, got %v", vm.Status(1))
+	}
+
+	plan, err := vm.Plan([]int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 1}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+	if _, err := vm.Vend([]int{1, 1}); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+	if err := vm.Apply(Plan{{Bucket: 1, Count: 1}}); err != OutOfServiceErr {
+		t.Fatalf("Expected out of service, got %v", err)
+	}
+	if _, err := vm.Begin(Plan{{Bucket: 1, Count: 1}}); err != OutOfServiceErr {
+		t.Fatalf("Expected out of service, got %v", err)
+	}
+
+	if err := vm.SetStatus(1, Active); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Vend([]int{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{2}, {}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+}
+
+func TestVendingMachine_Expiry(t *testing.T) {
+	now := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	past := now.AddDate(0, 0, -1)
+	soon := now.AddDate(0, 0, 1)
+	later := now.AddDate(0, 0, 7)
+
+	vm := New([][]int{{}, {}, {2}})
+	if err := vm.RestockWithExpiry(0, []int{1, 2}, []time.Time{later, later}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RestockWithExpiry(1, []int{1, 1}, []time.Time{past, soon}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.RestockWithExpiry(1, []int{1}, nil); err != InvalidArgument {
+		t.Fatalf("Expected invalid argument, got %v", err)
+	}
+
+	// The expired product blocks the one expiring soonest
+	plan, err := vm.PlanAt([]int{1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 1}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+
+	report := vm.ClearExpired(now)
+	if len(report.Expired) != 1 || report.Expired[0].Bucket != 1 || report.Expired[0].Product != 1 {
+		t.Fatalf("Invalid report %s", report)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1, 2}, {1}, {2}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+
+	plan, err = vm.PlanAt([]int{1, 2}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 2}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+	plan, err = vm.PlanAt([]int{1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 1, Count: 1}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+	if err := vm.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := vm.Expired(later); len(report.Expired) != 2 {
+		t.Fatalf("Expected both products of bucket 0 to expire, got %s", report)
+	}
+	if _, err := vm.PlanAt([]int{1}, later); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+}
+
+func TestVendingMachine_Hold(t *testing.T) {
+	vm := New([][]int{{1, 2}, {1}})
+
+	id, plan, err := vm.Hold([]int{1, 2}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, Plan{{Bucket: 0, Count: 2}}) {
+		t.Fatalf("Invalid plan %+v", plan)
+	}
+	if _, err := vm.Vend([]int{2}); !errors.Is(err, ImpossibleErr) {
+		t.Fatalf("Expected impossible, got %v", err)
+	}
+	if err := vm.Apply(Plan{{Bucket: 0, Count: 1}}); err != HeldBucketErr {
+		t.Fatalf("Expected held bucket, got %v", err)
+	}
+	if err := vm.Replace(0, []int{1, 2}); err != HeldBucketErr {
+		t.Fatalf("Expected held bucket, got %v", err)
+	}
+	if err := vm.Restock(0, []int{3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	fulfilled, err := vm.Fulfil(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fulfilled, plan) {
+		t.Fatalf("Invalid plan %+v", fulfilled)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{3}, {}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+	if err := vm.Release(id); err != HoldNotFoundErr {
+		t.Fatalf("Expected hold not found, got %v", err)
+	}
+}
+
+func TestVendingMachine_FulfilChecks(t *testing.T) {
+	now := time.Now()
+	later := now.AddDate(0, 0, 7)
+
+	vm := New([][]int{{}, {2}})
+	if err := vm.RestockWithExpiry(0, []int{1}, []time.Time{now.AddDate(0, 0, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	expiring, _, err := vm.Hold([]int{1}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jammed, _, err := vm.Hold([]int{2}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.FulfilAt(expiring, later); err != HeldProductsExpiredErr {
+		t.Fatalf("Expected held products expired, got %v", err)
+	}
+	if err := vm.SetStatus(1, Jammed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Fulfil(jammed); err != OutOfServiceErr {
+		t.Fatalf("Expected out of service, got %v", err)
+	}
+	if !reflect.DeepEqual(vm.Buckets(), [][]int{{1}, {2}}) {
+		t.Fatalf("Invalid buckets %+v", vm.Buckets())
+	}
+
+	if err := vm.SetStatus(1, Active); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Fulfil(jammed); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Release(expiring); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVendingMachine_HoldExpired(t *testing.T) {
+	vm := New([][]int{{1}})
+
+	id, _, err := vm.Hold([]int{1}, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Fulfil(id); err != HoldExpiredErr {
+		t.Fatalf("Expected hold expired, got %v", err)
+	}
+	if _, err := vm.Vend([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+}